@@ -44,6 +44,7 @@ func CreateIndexes(ctx context.Context, db *mongo.Database) error {
 		{Keys: bson.D{{Key: "customer_id", Value: 1}}},
 		{Keys: bson.D{{Key: "purchase_date", Value: -1}}},
 		{Keys: bson.D{{Key: "category", Value: 1}}},
+		{Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "customer_id", Value: 1}}},
 	}
 	_, err = purchaseCollection.Indexes().CreateMany(ctx, purchaseIndexes)
 	if err != nil {
@@ -67,6 +68,7 @@ func CreateIndexes(ctx context.Context, db *mongo.Database) error {
 	performanceIndexes := []mongo.IndexModel{
 		{Keys: bson.D{{Key: "campaign_id", Value: 1}}},
 		{Keys: bson.D{{Key: "date", Value: -1}}},
+		{Keys: bson.D{{Key: "campaign_id", Value: 1}, {Key: "variant_id", Value: 1}, {Key: "date", Value: 1}}},
 	}
 	_, err = performanceCollection.Indexes().CreateMany(ctx, performanceIndexes)
 	if err != nil {
@@ -75,13 +77,47 @@ func CreateIndexes(ctx context.Context, db *mongo.Database) error {
 
 	// Customer segments collection indexes
 	segmentCollection := db.Collection("customer_segments")
-	segmentIndex := mongo.IndexModel{
-		Keys:    bson.D{{Key: "segment_id", Value: 1}},
-		Options: options.Index().SetUnique(true),
+	segmentIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "segment_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "run_id", Value: 1}, {Key: "index", Value: 1}}},
+	}
+	_, err = segmentCollection.Indexes().CreateMany(ctx, segmentIndexes)
+	if err != nil {
+		log.Printf("Failed to create segment indexes: %v", err)
+	}
+
+	// Segmentation runs collection indexes
+	runCollection := db.Collection("segmentation_runs")
+	runIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "run_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "created_at", Value: -1}}},
+	}
+	_, err = runCollection.Indexes().CreateMany(ctx, runIndexes)
+	if err != nil {
+		log.Printf("Failed to create segmentation run indexes: %v", err)
+	}
+
+	// Segment memberships collection indexes
+	membershipCollection := db.Collection("segment_memberships")
+	membershipIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "segment_id", Value: 1}, {Key: "customer_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "run_id", Value: 1}, {Key: "customer_id", Value: 1}}},
+		{Keys: bson.D{{Key: "customer_id", Value: 1}}},
 	}
-	_, err = segmentCollection.Indexes().CreateOne(ctx, segmentIndex)
+	_, err = membershipCollection.Indexes().CreateMany(ctx, membershipIndexes)
 	if err != nil {
-		log.Printf("Failed to create segment index: %v", err)
+		log.Printf("Failed to create segment membership indexes: %v", err)
+	}
+
+	// RFM scores collection indexes
+	rfmCollection := db.Collection("rfm_scores")
+	rfmIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "customer_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "segment", Value: 1}}},
+	}
+	_, err = rfmCollection.Indexes().CreateMany(ctx, rfmIndexes)
+	if err != nil {
+		log.Printf("Failed to create RFM score indexes: %v", err)
 	}
 
 	// Predictions collection indexes
@@ -90,12 +126,80 @@ func CreateIndexes(ctx context.Context, db *mongo.Database) error {
 		{Keys: bson.D{{Key: "customer_id", Value: 1}}},
 		{Keys: bson.D{{Key: "prediction_type", Value: 1}}},
 		{Keys: bson.D{{Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "customer_id", Value: 1}, {Key: "prediction_type", Value: 1}, {Key: "created_at", Value: -1}}},
 	}
 	_, err = predictionCollection.Indexes().CreateMany(ctx, predictionIndexes)
 	if err != nil {
 		log.Printf("Failed to create prediction indexes: %v", err)
 	}
 
+	// Prediction jobs collection indexes
+	jobCollection := db.Collection("prediction_jobs")
+	jobIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "job_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err = jobCollection.Indexes().CreateOne(ctx, jobIndex)
+	if err != nil {
+		log.Printf("Failed to create prediction job index: %v", err)
+	}
+
+	// Customer features collection indexes
+	featureCollection := db.Collection("customer_features")
+	featureIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "customer_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+	_, err = featureCollection.Indexes().CreateOne(ctx, featureIndex)
+	if err != nil {
+		log.Printf("Failed to create customer feature index: %v", err)
+	}
+
+	// Events collection indexes
+	eventCollection := db.Collection("events")
+	eventIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "customer_id", Value: 1}, {Key: "timestamp", Value: 1}}},
+		{Keys: bson.D{{Key: "event_type", Value: 1}, {Key: "timestamp", Value: 1}}},
+	}
+	_, err = eventCollection.Indexes().CreateMany(ctx, eventIndexes)
+	if err != nil {
+		log.Printf("Failed to create event indexes: %v", err)
+	}
+
+	// Touchpoints collection indexes
+	touchpointCollection := db.Collection("touchpoints")
+	touchpointIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "campaign_id", Value: 1}, {Key: "timestamp", Value: 1}}},
+		{Keys: bson.D{{Key: "customer_id", Value: 1}, {Key: "timestamp", Value: 1}}},
+	}
+	_, err = touchpointCollection.Indexes().CreateMany(ctx, touchpointIndexes)
+	if err != nil {
+		log.Printf("Failed to create touchpoint indexes: %v", err)
+	}
+
+	// Anomalies collection indexes
+	anomalyCollection := db.Collection("anomalies")
+	anomalyIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "metric", Value: 1}, {Key: "campaign_id", Value: 1}, {Key: "date", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "date", Value: -1}}},
+	}
+	_, err = anomalyCollection.Indexes().CreateMany(ctx, anomalyIndexes)
+	if err != nil {
+		log.Printf("Failed to create anomaly indexes: %v", err)
+	}
+
+	// ML models collection indexes
+	modelCollection := db.Collection("ml_models")
+	modelIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "model_type", Value: 1}, {Key: "trained_at", Value: -1}}},
+		{Keys: bson.D{{Key: "model_type", Value: 1}, {Key: "version", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "model_type", Value: 1}, {Key: "status", Value: 1}}},
+	}
+	_, err = modelCollection.Indexes().CreateMany(ctx, modelIndexes)
+	if err != nil {
+		log.Printf("Failed to create ML model indexes: %v", err)
+	}
+
 	log.Println("Database indexes created successfully")
 	return nil
 }
@@ -5,6 +5,7 @@ import (
 	"ai-analytics/internal/models"
 	"ai-analytics/internal/services"
 	"ai-analytics/internal/utils"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -94,6 +95,207 @@ func (h *AnalyticsHandler) CreatePurchase(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"purchase": createdPurchase})
 }
 
+// Prediction History
+
+func (h *AnalyticsHandler) GetCustomerPredictions(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter"})
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset parameter"})
+		return
+	}
+
+	dateRange, err := parseDateRangeQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	predictions, total, err := h.analyticsService.GetCustomerPredictions(c.Request.Context(), c.Param("id"), c.Query("type"), dateRange, limit, offset)
+	if err != nil {
+		if errors.Is(err, services.ErrCustomerNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"predictions": predictions,
+		"total":       total,
+		"limit":       limit,
+		"offset":      offset,
+	})
+}
+
+func (h *AnalyticsHandler) GetCustomerPredictionTrend(c *gin.Context) {
+	dateRange, err := parseDateRangeQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	trends, err := h.analyticsService.GetCustomerPredictionTrend(c.Request.Context(), c.Param("id"), dateRange)
+	if err != nil {
+		if errors.Is(err, services.ErrCustomerNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"trends": trends})
+}
+
+// parseDateRangeQuery reads optional start_date and end_date query parameters
+// in YYYY-MM-DD format. The end date is inclusive.
+func parseDateRangeQuery(c *gin.Context) (models.DateRange, error) {
+	var dateRange models.DateRange
+
+	if startDateStr := c.Query("start_date"); startDateStr != "" {
+		startDate, err := time.Parse("2006-01-02", startDateStr)
+		if err != nil {
+			return dateRange, errors.New("Invalid start_date parameter")
+		}
+		dateRange.StartDate = startDate
+	}
+
+	if endDateStr := c.Query("end_date"); endDateStr != "" {
+		endDate, err := time.Parse("2006-01-02", endDateStr)
+		if err != nil {
+			return dateRange, errors.New("Invalid end_date parameter")
+		}
+		dateRange.EndDate = endDate.Add(24*time.Hour - time.Nanosecond)
+	}
+
+	return dateRange, nil
+}
+
+// Feature Store
+
+func (h *AnalyticsHandler) ComputeCustomerFeatures(c *gin.Context) {
+	processed, computedAt, err := h.analyticsService.ComputeCustomerFeatures(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"customers_processed": processed,
+		"computed_at":         computedAt,
+	})
+}
+
+func (h *AnalyticsHandler) GetCustomerFeatures(c *gin.Context) {
+	features, err := h.analyticsService.GetCustomerFeatures(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrCustomerNotFound) || errors.Is(err, services.ErrCustomerFeaturesNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"features": features})
+}
+
+// Segment Membership
+
+func (h *AnalyticsHandler) GetSegmentCustomers(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter"})
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset parameter"})
+		return
+	}
+
+	customers, total, err := h.analyticsService.GetSegmentCustomers(c.Request.Context(), c.Param("id"), limit, offset)
+	if err != nil {
+		if errors.Is(err, services.ErrSegmentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"customers": customers,
+		"total":     total,
+		"limit":     limit,
+		"offset":    offset,
+	})
+}
+
+func (h *AnalyticsHandler) GetCustomerSegments(c *gin.Context) {
+	segments, err := h.analyticsService.GetCustomerSegments(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrCustomerNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"segments": segments})
+}
+
+// Recommendations
+
+func (h *AnalyticsHandler) GetCustomerRecommendations(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter"})
+		return
+	}
+
+	recommendations, err := h.analyticsService.GetCustomerRecommendations(c.Request.Context(), c.Param("id"), limit)
+	if err != nil {
+		if errors.Is(err, services.ErrCustomerNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recommendations": recommendations})
+}
+
+func (h *AnalyticsHandler) GetSimilarProducts(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter"})
+		return
+	}
+
+	products, err := h.analyticsService.GetSimilarProducts(c.Request.Context(), c.Param("id"), limit)
+	if err != nil {
+		if errors.Is(err, services.ErrProductNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"products": products})
+}
+
 // Campaign Management
 
 func (h *AnalyticsHandler) CreateCampaign(c *gin.Context) {
@@ -110,6 +312,10 @@ func (h *AnalyticsHandler) CreateCampaign(c *gin.Context) {
 
 	createdCampaign, err := h.analyticsService.CreateCampaign(c.Request.Context(), campaign)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidCampaignVariants) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -148,6 +354,159 @@ func (h *AnalyticsHandler) CreateCampaignPerformance(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"performance": createdPerformance})
 }
 
+// Campaign Attribution
+
+func (h *AnalyticsHandler) TrackTouchpoints(c *gin.Context) {
+	var req models.TouchpointBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := utils.ValidateStruct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recorded, err := h.analyticsService.TrackTouchpoints(c.Request.Context(), req.Touchpoints)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidTouchpoints) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"touchpoints_recorded": recorded})
+}
+
+func (h *AnalyticsHandler) GetCampaignAttribution(c *gin.Context) {
+	lookbackDays, err := strconv.Atoi(c.DefaultQuery("lookback_days", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid lookback_days parameter"})
+		return
+	}
+
+	halfLifeDays, err := strconv.ParseFloat(c.DefaultQuery("half_life_days", "0"), 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid half_life_days parameter"})
+		return
+	}
+
+	dateRange, err := parseDateRangeQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	attribution, err := h.analyticsService.AttributeCampaign(c.Request.Context(), c.Param("id"), models.AttributionRequest{
+		Model:        c.Query("model"),
+		LookbackDays: lookbackDays,
+		HalfLifeDays: halfLifeDays,
+		DateRange:    dateRange,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAttributionParameter) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrCampaignNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"attribution": attribution})
+}
+
+// Campaign Experiments
+
+func (h *AnalyticsHandler) GetCampaignExperiment(c *gin.Context) {
+	confidence, err := strconv.ParseFloat(c.DefaultQuery("confidence", "0"), 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid confidence parameter"})
+		return
+	}
+
+	mde, err := strconv.ParseFloat(c.DefaultQuery("mde", "0"), 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mde parameter"})
+		return
+	}
+
+	power, err := strconv.ParseFloat(c.DefaultQuery("power", "0"), 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid power parameter"})
+		return
+	}
+
+	dateRange, err := parseDateRangeQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	experiment, err := h.analyticsService.AnalyzeExperiment(c.Request.Context(), c.Param("id"), models.ExperimentRequest{
+		Control:             c.Query("control"),
+		ConfidenceLevel:     confidence,
+		MinDetectableEffect: mde,
+		Power:               power,
+		DateRange:           dateRange,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidExperimentParameter) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrCampaignNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"experiment": experiment})
+}
+
+func (h *AnalyticsHandler) GetVariantAllocation(c *gin.Context) {
+	batch, err := strconv.Atoi(c.DefaultQuery("batch", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch parameter"})
+		return
+	}
+
+	dateRange, err := parseDateRangeQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	allocation, err := h.analyticsService.RecommendVariantAllocation(c.Request.Context(), c.Param("id"), models.BanditRequest{
+		Algorithm: c.Query("algorithm"),
+		Metric:    c.Query("metric"),
+		Batch:     batch,
+		DateRange: dateRange,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidBanditParameter) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrCampaignNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"allocation": allocation})
+}
+
 // AI Analytics
 
 func (h *AnalyticsHandler) PerformSegmentation(c *gin.Context) {
@@ -162,13 +521,94 @@ func (h *AnalyticsHandler) PerformSegmentation(c *gin.Context) {
 		return
 	}
 
-	segments, err := h.analyticsService.PerformCustomerSegmentation(c.Request.Context(), req)
+	run, segments, err := h.analyticsService.PerformCustomerSegmentation(c.Request.Context(), req)
 	if err != nil {
+		if errors.Is(err, services.ErrUnsupportedFeature) || errors.Is(err, services.ErrUnsupportedAlgorithm) || errors.Is(err, services.ErrInvalidParameter) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"segments": segments})
+	c.JSON(http.StatusOK, gin.H{
+		"run":                  run,
+		"segments":             segments,
+		"metrics":              run.Metrics,
+		"customers_considered": run.CustomersConsidered,
+	})
+}
+
+func (h *AnalyticsHandler) GetRFMScores(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter"})
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset parameter"})
+		return
+	}
+
+	scores, err := h.analyticsService.GetRFMScores(c.Request.Context(), c.Query("segment"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rfm_scores": scores})
+}
+
+func (h *AnalyticsHandler) GetSegmentationRuns(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter"})
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset parameter"})
+		return
+	}
+
+	runs, err := h.analyticsService.GetSegmentationRuns(c.Request.Context(), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"runs": runs})
+}
+
+func (h *AnalyticsHandler) GetSegmentationRun(c *gin.Context) {
+	run, segments, err := h.analyticsService.GetSegmentationRun(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrSegmentationRunNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"run": run, "segments": segments})
+}
+
+func (h *AnalyticsHandler) DiffSegmentationRuns(c *gin.Context) {
+	diff, err := h.analyticsService.DiffSegmentationRuns(c.Request.Context(), c.Param("id"), c.Param("other_id"))
+	if err != nil {
+		if errors.Is(err, services.ErrSegmentationRunNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"diff": diff})
 }
 
 func (h *AnalyticsHandler) PredictCustomerBehavior(c *gin.Context) {
@@ -185,6 +625,10 @@ func (h *AnalyticsHandler) PredictCustomerBehavior(c *gin.Context) {
 
 	prediction, err := h.analyticsService.PredictCustomerBehavior(c.Request.Context(), req)
 	if err != nil {
+		if errors.Is(err, services.ErrUnsupportedPredictionType) || errors.Is(err, services.ErrInvalidHorizon) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -192,6 +636,342 @@ func (h *AnalyticsHandler) PredictCustomerBehavior(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"prediction": prediction})
 }
 
+func (h *AnalyticsHandler) StartBatchPrediction(c *gin.Context) {
+	var req models.BatchPredictionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := utils.ValidateStruct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := h.analyticsService.StartBatchPrediction(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrSegmentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrInvalidBatchSelection) ||
+			errors.Is(err, services.ErrUnsupportedPredictionType) ||
+			errors.Is(err, services.ErrInvalidHorizon) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"job": job})
+}
+
+func (h *AnalyticsHandler) GetPredictionJob(c *gin.Context) {
+	job, err := h.analyticsService.GetPredictionJob(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrPredictionJobNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"job": job})
+}
+
+// Model Training
+
+func (h *AnalyticsHandler) TrainChurnModel(c *gin.Context) {
+	var req models.ChurnTrainingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	model, err := h.analyticsService.TrainChurnModel(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidTrainingWindow) || errors.Is(err, services.ErrInsufficientTrainingData) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"model": model})
+}
+
+func (h *AnalyticsHandler) TrainLTVModel(c *gin.Context) {
+	var req models.LTVTrainingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	model, err := h.analyticsService.TrainLTVModel(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrInsufficientTrainingData) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"model": model})
+}
+
+func (h *AnalyticsHandler) EvaluatePredictions(c *gin.Context) {
+	var req models.EvaluationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.analyticsService.EvaluatePredictions(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidEvaluationWindow) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"evaluation": report})
+}
+
+func (h *AnalyticsHandler) AnalyzeBaskets(c *gin.Context) {
+	var req models.BasketAnalysisRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.analyticsService.AnalyzeBaskets(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidBasketParameter) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"basket_analysis": result})
+}
+
+func (h *AnalyticsHandler) GetForecast(c *gin.Context) {
+	horizon, err := strconv.Atoi(c.DefaultQuery("horizon", "30"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid horizon parameter"})
+		return
+	}
+
+	historyDays, err := strconv.Atoi(c.DefaultQuery("history_days", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid history_days parameter"})
+		return
+	}
+
+	forecast, err := h.analyticsService.ForecastMetric(c.Request.Context(), models.ForecastRequest{
+		Metric:      c.Query("metric"),
+		Horizon:     horizon,
+		GroupBy:     c.Query("group_by"),
+		Seasonality: c.Query("seasonality"),
+		HistoryDays: historyDays,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidForecastParameter) || errors.Is(err, services.ErrInsufficientHistory) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"forecast": forecast})
+}
+
+func (h *AnalyticsHandler) GetCohortRetention(c *gin.Context) {
+	months, err := strconv.Atoi(c.DefaultQuery("months", "12"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid months parameter"})
+		return
+	}
+
+	cohorts, err := h.analyticsService.GetCohortRetention(c.Request.Context(), c.Query("cohort_by"), months)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCohortParameter) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"cohort_analysis": cohorts})
+}
+
+// Events and Funnels
+
+func (h *AnalyticsHandler) TrackEvents(c *gin.Context) {
+	var req models.EventBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := utils.ValidateStruct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recorded, err := h.analyticsService.TrackEvents(c.Request.Context(), req.Events)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidEvents) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"events_recorded": recorded})
+}
+
+func (h *AnalyticsHandler) AnalyzeFunnel(c *gin.Context) {
+	var req models.FunnelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := utils.ValidateStruct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	funnel, err := h.analyticsService.AnalyzeFunnel(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidFunnelParameter) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrSegmentationRunNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"funnel": funnel})
+}
+
+// Anomaly Detection
+
+func (h *AnalyticsHandler) DetectAnomalies(c *gin.Context) {
+	var req models.AnomalyDetectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	anomalies, err := h.analyticsService.DetectAnomalies(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAnomalyParameter) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"anomalies": anomalies})
+}
+
+func (h *AnalyticsHandler) GetAnomalies(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter"})
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset parameter"})
+		return
+	}
+
+	dateRange, err := parseDateRangeQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	anomalies, total, err := h.analyticsService.GetAnomalies(c.Request.Context(), c.Query("metric"), c.Query("campaign_id"), c.Query("severity"), dateRange, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"anomalies": anomalies,
+		"total":     total,
+		"limit":     limit,
+		"offset":    offset,
+	})
+}
+
+// Model Registry
+
+func (h *AnalyticsHandler) GetModelVersions(c *gin.Context) {
+	versions, err := h.analyticsService.GetModelVersions(c.Request.Context(), c.Param("type"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"models": versions})
+}
+
+func (h *AnalyticsHandler) PromoteModel(c *gin.Context) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version parameter"})
+		return
+	}
+
+	model, err := h.analyticsService.PromoteModel(c.Request.Context(), c.Param("type"), version)
+	if err != nil {
+		if errors.Is(err, services.ErrModelNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"model": model})
+}
+
+func (h *AnalyticsHandler) RollbackModel(c *gin.Context) {
+	model, err := h.analyticsService.RollbackModel(c.Request.Context(), c.Param("type"))
+	if err != nil {
+		if errors.Is(err, services.ErrNoRollbackTarget) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"model": model})
+}
+
 func (h *AnalyticsHandler) OptimizeCampaign(c *gin.Context) {
 	var req models.CampaignOptimizationRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -213,6 +993,31 @@ func (h *AnalyticsHandler) OptimizeCampaign(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"optimization": optimization})
 }
 
+func (h *AnalyticsHandler) AllocateBudget(c *gin.Context) {
+	var req models.BudgetAllocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := utils.ValidateStruct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	allocation, err := h.analyticsService.AllocateBudgetAcrossCampaigns(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidBudgetParameter) || errors.Is(err, services.ErrInsufficientPerformanceData) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"allocation": allocation})
+}
+
 func (h *AnalyticsHandler) GetDashboard(c *gin.Context) {
 	var dateRange models.DateRange
 
@@ -36,6 +36,24 @@ type Purchase struct {
 	CreatedAt    time.Time          `json:"created_at" bson:"created_at"`
 }
 
+// CustomerFeatures holds features derived from a customer's purchases,
+// computed by the feature store
+type CustomerFeatures struct {
+	ID                    primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	CustomerID            string             `json:"customer_id" bson:"customer_id"`
+	PurchaseCount         int                `json:"purchase_count" bson:"purchase_count"`
+	AvgOrderValue         float64            `json:"avg_order_value" bson:"avg_order_value"`
+	InterPurchaseMeanDays float64            `json:"inter_purchase_mean_days" bson:"inter_purchase_mean_days"` // 0 with fewer than two purchases
+	InterPurchaseStdDays  float64            `json:"inter_purchase_std_days" bson:"inter_purchase_std_days"`
+	CategoryDiversity     int                `json:"category_diversity" bson:"category_diversity"` // Distinct categories purchased
+	OnlineRatio           float64            `json:"online_ratio" bson:"online_ratio"`             // Share of purchases made online
+	DaysSinceRegistration float64            `json:"days_since_registration" bson:"days_since_registration"`
+	Spend30d              float64            `json:"spend_30d" bson:"spend_30d"`
+	Spend90d              float64            `json:"spend_90d" bson:"spend_90d"`
+	Spend365d             float64            `json:"spend_365d" bson:"spend_365d"`
+	ComputedAt            time.Time          `json:"computed_at" bson:"computed_at"`
+}
+
 // MarketingCampaign represents marketing campaign data
 type MarketingCampaign struct {
 	ID            primitive.ObjectID `json:"id" bson:"_id,omitempty"`
@@ -47,14 +65,23 @@ type MarketingCampaign struct {
 	StartDate     time.Time          `json:"start_date" bson:"start_date"`
 	EndDate       time.Time          `json:"end_date" bson:"end_date"`
 	Status        string             `json:"status" bson:"status"` // active, paused, completed
+	Variants      []CampaignVariant  `json:"variants,omitempty" bson:"variants,omitempty" validate:"dive"`
 	CreatedAt     time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt     time.Time          `json:"updated_at" bson:"updated_at"`
 }
 
+// CampaignVariant represents one arm of a campaign experiment
+type CampaignVariant struct {
+	VariantID string `json:"variant_id" bson:"variant_id" validate:"required"`
+	Name      string `json:"name" bson:"name"`
+	IsControl bool   `json:"is_control" bson:"is_control"`
+}
+
 // CampaignPerformance represents campaign performance metrics
 type CampaignPerformance struct {
 	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 	CampaignID  string             `json:"campaign_id" bson:"campaign_id"`
+	VariantID   string             `json:"variant_id,omitempty" bson:"variant_id,omitempty"`
 	Impressions int                `json:"impressions" bson:"impressions"`
 	Clicks      int                `json:"clicks" bson:"clicks"`
 	Conversions int                `json:"conversions" bson:"conversions"`
@@ -71,6 +98,8 @@ type CampaignPerformance struct {
 type CustomerSegment struct {
 	ID          primitive.ObjectID     `json:"id" bson:"_id,omitempty"`
 	SegmentID   string                 `json:"segment_id" bson:"segment_id"`
+	RunID       string                 `json:"run_id" bson:"run_id"`
+	Index       int                    `json:"index" bson:"index"` // Position of the segment within its run
 	Name        string                 `json:"name" bson:"name"`
 	Description string                 `json:"description" bson:"description"`
 	Criteria    map[string]interface{} `json:"criteria" bson:"criteria"`
@@ -79,15 +108,162 @@ type CustomerSegment struct {
 	UpdatedAt   time.Time              `json:"updated_at" bson:"updated_at"`
 }
 
+// SegmentMembership records that a customer belongs to a customer segment
+type SegmentMembership struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	RunID      string             `json:"run_id" bson:"run_id"`
+	SegmentID  string             `json:"segment_id" bson:"segment_id"`
+	CustomerID string             `json:"customer_id" bson:"customer_id"`
+	CreatedAt  time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// RFMScore represents a customer's recency, frequency and monetary quintile
+// scores and the named RFM segment they map to
+type RFMScore struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	CustomerID  string             `json:"customer_id" bson:"customer_id"`
+	RunID       string             `json:"run_id" bson:"run_id"`
+	RecencyDays float64            `json:"recency_days" bson:"recency_days"`
+	Frequency   int                `json:"frequency" bson:"frequency"`
+	Monetary    float64            `json:"monetary" bson:"monetary"`
+	RScore      int                `json:"r_score" bson:"r_score"` // 1-5, 5 is most recent
+	FScore      int                `json:"f_score" bson:"f_score"`
+	MScore      int                `json:"m_score" bson:"m_score"`
+	RFMScore    string             `json:"rfm_score" bson:"rfm_score"` // e.g. "545"
+	Segment     string             `json:"segment" bson:"segment"`     // Champions, Loyal Customers, At Risk, ...
+	ComputedAt  time.Time          `json:"computed_at" bson:"computed_at"`
+}
+
+// SegmentationRun represents a single segmentation execution and the
+// segments it produced
+type SegmentationRun struct {
+	ID                  primitive.ObjectID     `json:"id" bson:"_id,omitempty"`
+	RunID               string                 `json:"run_id" bson:"run_id"`
+	Algorithm           string                 `json:"algorithm" bson:"algorithm"`
+	Features            []string               `json:"features" bson:"features"`
+	Parameters          map[string]interface{} `json:"parameters" bson:"parameters"`
+	SegmentIDs          []string               `json:"segment_ids" bson:"segment_ids"`
+	CustomerCount       int                    `json:"customer_count" bson:"customer_count"`             // Sampled customers assigned to a segment
+	CustomersConsidered int                    `json:"customers_considered" bson:"customers_considered"` // Customers the segmentation was fitted on, after sampling
+	SamplingRate        float64                `json:"sampling_rate" bson:"sampling_rate"`
+	MiniBatch           bool                   `json:"mini_batch" bson:"mini_batch"`
+	Status              string                 `json:"status" bson:"status"` // pending, completed
+	Metrics             *ClusterQualityMetrics `json:"metrics,omitempty" bson:"metrics,omitempty"`
+	CreatedAt           time.Time              `json:"created_at" bson:"created_at"`
+}
+
+// ClusterQualityMetrics describes how well separated a segmentation is
+type ClusterQualityMetrics struct {
+	ClusterCount       int          `json:"cluster_count" bson:"cluster_count"`
+	NoiseCount         int          `json:"noise_count" bson:"noise_count"`
+	SilhouetteScore    float64      `json:"silhouette_score" bson:"silhouette_score"`           // -1 to 1, higher is better
+	DaviesBouldinIndex float64      `json:"davies_bouldin_index" bson:"davies_bouldin_index"`   // lower is better
+	WCSS               float64      `json:"wcss" bson:"wcss"`                                   // Within-cluster sum of squares
+	SampleSize         int          `json:"sample_size" bson:"sample_size"`                     // Points used for the silhouette score
+	ElbowCurve         []ElbowPoint `json:"elbow_curve,omitempty" bson:"elbow_curve,omitempty"` // k-means runs only
+	SelectedK          int          `json:"selected_k,omitempty" bson:"selected_k,omitempty"`   // Set when auto_k picked k
+}
+
+// ElbowPoint is the k-means fit quality for a single k
+type ElbowPoint struct {
+	K          int     `json:"k" bson:"k"`
+	WCSS       float64 `json:"wcss" bson:"wcss"`
+	Silhouette float64 `json:"silhouette" bson:"silhouette"`
+}
+
+// SegmentMigration counts customers that moved from one segment to another
+// between two segmentation runs
+type SegmentMigration struct {
+	FromSegmentID   string `json:"from_segment_id"`
+	FromSegmentName string `json:"from_segment_name"`
+	ToSegmentID     string `json:"to_segment_id"`
+	ToSegmentName   string `json:"to_segment_name"`
+	CustomerCount   int    `json:"customer_count"`
+}
+
+// SegmentationRunDiff describes how customers migrated between two runs
+type SegmentationRunDiff struct {
+	FromRunID        string             `json:"from_run_id"`
+	ToRunID          string             `json:"to_run_id"`
+	Migrations       []SegmentMigration `json:"migrations"`
+	CommonCustomers  int                `json:"common_customers"`
+	AddedCustomers   int                `json:"added_customers"`   // only in the later run
+	RemovedCustomers int                `json:"removed_customers"` // only in the earlier run
+}
+
 // PredictionResult represents AI prediction results
 type PredictionResult struct {
-	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	CustomerID     string             `json:"customer_id" bson:"customer_id"`
-	PredictionType string             `json:"prediction_type" bson:"prediction_type"` // churn, ltv, next_purchase
-	Probability    float64            `json:"probability" bson:"probability"`
-	Value          float64            `json:"value" bson:"value"`
-	Confidence     float64            `json:"confidence" bson:"confidence"`
-	CreatedAt      time.Time          `json:"created_at" bson:"created_at"`
+	ID                primitive.ObjectID     `json:"id" bson:"_id,omitempty"`
+	CustomerID        string                 `json:"customer_id" bson:"customer_id"`
+	PredictionType    string                 `json:"prediction_type" bson:"prediction_type"` // churn, ltv, next_purchase
+	Probability       float64                `json:"probability" bson:"probability"`
+	Value             float64                `json:"value" bson:"value"`
+	Confidence        float64                `json:"confidence" bson:"confidence"`
+	HorizonMonths     int                    `json:"horizon_months,omitempty" bson:"horizon_months,omitempty"`         // ltv only
+	ExpectedPurchases float64                `json:"expected_purchases,omitempty" bson:"expected_purchases,omitempty"` // ltv only
+	Interval          *PredictionInterval    `json:"interval,omitempty" bson:"interval,omitempty"`
+	ModelVersion      int                    `json:"model_version,omitempty" bson:"model_version,omitempty"` // 0 when a heuristic was used
+	Explanation       *PredictionExplanation `json:"explanation,omitempty" bson:"explanation,omitempty"`
+	CreatedAt         time.Time              `json:"created_at" bson:"created_at"`
+}
+
+// PredictionTrend shows how one type of prediction for a customer evolved
+// across scoring runs
+type PredictionTrend struct {
+	PredictionType string       `json:"prediction_type"`
+	HorizonMonths  int          `json:"horizon_months,omitempty"` // ltv only, one trend per horizon
+	Points         []TrendPoint `json:"points"`                   // Oldest first
+	First          float64      `json:"first"`
+	Latest         float64      `json:"latest"`
+	Change         float64      `json:"change"`
+	SlopePerMonth  float64      `json:"slope_per_month"` // Least-squares slope of the points
+	Deteriorating  bool         `json:"deteriorating"`   // Churn risk rising or lifetime value falling
+}
+
+// TrendPoint is one scored value in a PredictionTrend
+type TrendPoint struct {
+	CreatedAt    time.Time `json:"created_at"`
+	Value        float64   `json:"value"`
+	ModelVersion int       `json:"model_version,omitempty"`
+}
+
+// ProductRecommendation is a product suggested for a customer or as similar
+// to another product
+type ProductRecommendation struct {
+	ProductID string  `json:"product_id"`
+	Category  string  `json:"category"`
+	Score     float64 `json:"score"`
+	Source    string  `json:"source"` // collaborative, popular
+}
+
+// PredictionExplanation attributes a prediction to its input features. For
+// linear models contributions are in log-odds relative to the intercept;
+// otherwise they are Shapley values relative to the prediction for an
+// average customer (BaseValue), so they sum to the prediction minus
+// BaseValue. The average customer is stored when customer features are
+// computed, so heuristic predictions carry no explanation before that.
+type PredictionExplanation struct {
+	Method    string                `json:"method" bson:"method"` // linear, shapley
+	BaseValue float64               `json:"base_value" bson:"base_value"`
+	Features  []FeatureContribution `json:"features" bson:"features"` // Largest contributions first
+}
+
+// FeatureContribution is the value of one input feature and how much it
+// moved the prediction
+type FeatureContribution struct {
+	Feature      string  `json:"feature" bson:"feature"`
+	Value        float64 `json:"value" bson:"value"`
+	Contribution float64 `json:"contribution" bson:"contribution"`
+}
+
+// PredictionInterval holds the bounds of a prediction at a given coverage
+// level
+type PredictionInterval struct {
+	Level          float64 `json:"level" bson:"level"`
+	PurchasesLower float64 `json:"purchases_lower" bson:"purchases_lower"`
+	PurchasesUpper float64 `json:"purchases_upper" bson:"purchases_upper"`
+	ValueLower     float64 `json:"value_lower" bson:"value_lower"`
+	ValueUpper     float64 `json:"value_upper" bson:"value_upper"`
 }
 
 // AnalyticsRequest represents request for analytics operations
@@ -103,17 +279,47 @@ type DateRange struct {
 	EndDate   time.Time `json:"end_date"`
 }
 
-// SegmentationRequest represents customer segmentation request
+// SegmentationRequest represents customer segmentation request. With a
+// sample_rate below 1 only the sampled customers are segmented, whether or
+// not mini-batch k-means is used; the rest get no segment membership.
 type SegmentationRequest struct {
-	Algorithm  string                 `json:"algorithm" validate:"required"` // kmeans, dbscan
-	Features   []string               `json:"features" validate:"required"`
-	Parameters map[string]interface{} `json:"parameters"`
+	Algorithm  string                 `json:"algorithm" validate:"required"` // kmeans, dbscan, hierarchical, rfm
+	Features   []string               `json:"features"`                      // required except for rfm; customer fields (age, total_spent, purchase_frequency, days_since_registration, days_since_last_purchase) or stored features (avg_order_value, inter_purchase_mean_days, inter_purchase_std_days, category_diversity, online_ratio, spend_30d, spend_90d, spend_365d)
+	Parameters map[string]interface{} `json:"parameters"`                    // k, auto_k, min_k, max_k, max_iterations, tolerance, seed, eps, min_pts, linkage, distance_threshold, sample_rate, mini_batch_threshold, batch_size, epochs
 }
 
 // PredictionRequest represents prediction request
 type PredictionRequest struct {
 	CustomerID     string `json:"customer_id" validate:"required"`
 	PredictionType string `json:"prediction_type" validate:"required"`
+	HorizonMonths  int    `json:"horizon_months"` // ltv only, defaults to 12
+}
+
+// BatchPredictionRequest represents a request to score many customers in the
+// background. Exactly one of CustomerIDs, SegmentID or All selects them.
+type BatchPredictionRequest struct {
+	CustomerIDs     []string `json:"customer_ids"`
+	SegmentID       string   `json:"segment_id"`
+	All             bool     `json:"all"`
+	PredictionTypes []string `json:"prediction_types" validate:"required"` // churn, ltv, next_purchase
+	HorizonMonths   int      `json:"horizon_months"`                       // ltv only, defaults to 12
+}
+
+// PredictionJob tracks the progress of a batch prediction
+type PredictionJob struct {
+	ID                 primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	JobID              string             `json:"job_id" bson:"job_id"`
+	Status             string             `json:"status" bson:"status"` // running, completed, failed
+	PredictionTypes    []string           `json:"prediction_types" bson:"prediction_types"`
+	SegmentID          string             `json:"segment_id,omitempty" bson:"segment_id,omitempty"`
+	All                bool               `json:"all" bson:"all"`
+	HorizonMonths      int                `json:"horizon_months" bson:"horizon_months"`
+	TotalCustomers     int                `json:"total_customers" bson:"total_customers"`
+	ProcessedCustomers int                `json:"processed_customers" bson:"processed_customers"`
+	PredictionsWritten int                `json:"predictions_written" bson:"predictions_written"`
+	Error              string             `json:"error,omitempty" bson:"error,omitempty"`
+	CreatedAt          time.Time          `json:"created_at" bson:"created_at"`
+	CompletedAt        *time.Time         `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
 }
 
 // CampaignOptimizationRequest represents campaign optimization request
@@ -122,3 +328,350 @@ type CampaignOptimizationRequest struct {
 	Objective  string                 `json:"objective" validate:"required"` // maximize_roas, minimize_cost, maximize_conversions
 	Parameters map[string]interface{} `json:"parameters"`
 }
+
+// BasketAnalysisRequest represents market basket analysis request
+type BasketAnalysisRequest struct {
+	Level          string     `json:"level"`            // product (default) or category
+	Basket         string     `json:"basket"`           // customer_day (default), customer_week or customer
+	MinSupport     float64    `json:"min_support"`      // Share of baskets, default 0.01
+	MinConfidence  float64    `json:"min_confidence"`   // Default 0.1
+	MinLift        float64    `json:"min_lift"`         // Default 1
+	MaxItemsetSize int        `json:"max_itemset_size"` // Default 3
+	MaxRules       int        `json:"max_rules"`        // Default 100
+	DateRange      *DateRange `json:"date_range,omitempty"`
+}
+
+// AssociationRule states that baskets containing the antecedent items tend to
+// also contain the consequent items
+type AssociationRule struct {
+	Antecedent []string `json:"antecedent"`
+	Consequent []string `json:"consequent"`
+	Count      int      `json:"count"`      // Baskets containing both sides
+	Support    float64  `json:"support"`    // Share of baskets containing both sides
+	Confidence float64  `json:"confidence"` // Share of antecedent baskets that contain the consequent
+	Lift       float64  `json:"lift"`       // Confidence over the consequent's support
+}
+
+// BasketAnalysisResult holds the association rules mined from purchases,
+// strongest first
+type BasketAnalysisResult struct {
+	Level            string            `json:"level"`
+	Basket           string            `json:"basket"`
+	Baskets          int               `json:"baskets"`
+	FrequentItemsets int               `json:"frequent_itemsets"`
+	Rules            []AssociationRule `json:"rules"`
+}
+
+// ForecastRequest represents a request to forecast a daily purchases metric
+type ForecastRequest struct {
+	Metric      string // revenue (default) or units
+	Horizon     int    // Days to forecast, default 30
+	GroupBy     string // Empty for the total, category or channel
+	Seasonality string // weekly (default) or yearly
+	HistoryDays int    // Days of history to fit on, defaults to three seasons
+}
+
+// Forecast holds the forecast of a daily metric, per group when grouped
+type Forecast struct {
+	Metric        string          `json:"metric"`
+	GroupBy       string          `json:"group_by,omitempty"`
+	Seasonality   string          `json:"seasonality"`
+	SeasonLength  int             `json:"season_length"` // Days
+	Horizon       int             `json:"horizon"`
+	IntervalLevel float64         `json:"interval_level"`
+	HistoryStart  time.Time       `json:"history_start"`
+	HistoryEnd    time.Time       `json:"history_end"` // Exclusive
+	Groups        []GroupForecast `json:"groups"`
+}
+
+// GroupForecast compares the Holt-Winters forecast of one group with a
+// seasonal naive baseline
+type GroupForecast struct {
+	Group         string         `json:"group,omitempty"`
+	HoltWinters   ForecastSeries `json:"holt_winters"`
+	SeasonalNaive ForecastSeries `json:"seasonal_naive"`
+}
+
+// ForecastSeries is the forecast of one method
+type ForecastSeries struct {
+	Parameters map[string]float64 `json:"parameters,omitempty"` // Fitted smoothing parameters
+	RMSE       float64            `json:"rmse"`                 // Of the in-sample one-step-ahead errors
+	Points     []ForecastPoint    `json:"points"`
+}
+
+// ForecastPoint is the forecast for one day with its prediction interval
+type ForecastPoint struct {
+	Date  time.Time `json:"date"`
+	Value float64   `json:"value"`
+	Lower float64   `json:"lower"`
+	Upper float64   `json:"upper"`
+}
+
+// AnomalyDetectionRequest represents a request to scan recent daily metrics
+// for anomalies
+type AnomalyDetectionRequest struct {
+	LookbackDays int     `json:"lookback_days"` // Days scanned, ending yesterday, default 7
+	WindowDays   int     `json:"window_days"`   // Days of history each day is compared with, default 28
+	Threshold    float64 `json:"threshold"`     // Minimum absolute z-score, default 3
+}
+
+// Anomaly is a day on which a metric deviated from its expected value by more
+// than the detection threshold
+type Anomaly struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Metric     string             `json:"metric" bson:"metric"`                     // revenue, ctr, roas
+	CampaignID string             `json:"campaign_id,omitempty" bson:"campaign_id"` // Empty for store-wide metrics
+	Date       time.Time          `json:"date" bson:"date"`
+	Value      float64            `json:"value" bson:"value"`
+	Expected   float64            `json:"expected" bson:"expected"`
+	ZScore     float64            `json:"z_score" bson:"z_score"`
+	Direction  string             `json:"direction" bson:"direction"` // spike, drop
+	Severity   string             `json:"severity" bson:"severity"`   // low, medium, high
+	Method     string             `json:"method" bson:"method"`       // seasonal_residual, rolling_zscore
+	DetectedAt time.Time          `json:"detected_at" bson:"detected_at"`
+}
+
+// CohortAnalysis holds the monthly retention matrix of customer cohorts, one
+// row per cohort and one column per month since the cohort started
+type CohortAnalysis struct {
+	CohortBy string      `json:"cohort_by"` // registration or first_purchase
+	Cohorts  []CohortRow `json:"cohorts"`
+}
+
+// CohortRow is the activity of one cohort in each month since it started,
+// up to the current month
+type CohortRow struct {
+	Cohort    string    `json:"cohort"` // YYYY-MM
+	Size      int       `json:"size"`
+	Active    []int     `json:"active"`    // Customers who purchased in each month
+	Retention []float64 `json:"retention"` // Active over size
+	Revenue   []float64 `json:"revenue"`
+}
+
+// Event represents a tracked customer interaction on the way to a purchase
+type Event struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	EventType  string             `json:"event_type" bson:"event_type" validate:"required,oneof=view add_to_cart checkout purchase"`
+	CustomerID string             `json:"customer_id" bson:"customer_id" validate:"required"`
+	SessionID  string             `json:"session_id" bson:"session_id"`
+	ProductID  string             `json:"product_id,omitempty" bson:"product_id,omitempty"`
+	Channel    string             `json:"channel" bson:"channel"`     // online, store, as on purchases
+	Timestamp  time.Time          `json:"timestamp" bson:"timestamp"` // Defaults to the time of ingestion
+	CreatedAt  time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// EventBatchRequest represents a batch of events to record
+type EventBatchRequest struct {
+	Events []Event `json:"events" validate:"required,dive"`
+}
+
+// FunnelRequest represents conversion funnel analysis request
+type FunnelRequest struct {
+	Steps       []string   `json:"steps" validate:"required"` // Ordered event types, at least two
+	WindowHours int        `json:"window_hours"`              // Time allowed from the first step to the last, default 24
+	SegmentBy   string     `json:"segment_by"`                // Empty, channel or segment
+	RunID       string     `json:"run_id"`                    // Segmentation run for segment_by segment, default the latest
+	DateRange   *DateRange `json:"date_range,omitempty"`
+}
+
+// FunnelResult holds the funnel for all customers, followed by one per
+// segment when segmented
+type FunnelResult struct {
+	Steps       []string      `json:"steps"`
+	WindowHours int           `json:"window_hours"`
+	SegmentBy   string        `json:"segment_by,omitempty"`
+	RunID       string        `json:"run_id,omitempty"`
+	Groups      []FunnelGroup `json:"groups"`
+}
+
+// FunnelGroup is the funnel of one group of customers
+type FunnelGroup struct {
+	Segment           string       `json:"segment,omitempty"` // Empty for all customers
+	Steps             []FunnelStep `json:"steps"`
+	OverallConversion float64      `json:"overall_conversion"` // Share of entrants who completed every step
+}
+
+// FunnelStep counts the customers who reached a step of a funnel
+type FunnelStep struct {
+	Step                      string  `json:"step"`
+	Customers                 int     `json:"customers"`
+	ConversionRate            float64 `json:"conversion_rate"`              // Share of the first step's customers
+	DropOffRate               float64 `json:"drop_off_rate"`                // Share of the previous step's customers lost
+	MedianSecondsFromPrevious float64 `json:"median_seconds_from_previous"` // Zero for the first step
+}
+
+// Touchpoint records a customer's interaction with a campaign
+type Touchpoint struct {
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	CustomerID string             `json:"customer_id" bson:"customer_id" validate:"required"`
+	CampaignID string             `json:"campaign_id" bson:"campaign_id" validate:"required"`
+	Type       string             `json:"type" bson:"type" validate:"required,oneof=impression click open visit"`
+	Timestamp  time.Time          `json:"timestamp" bson:"timestamp"` // Defaults to the time of ingestion
+	CreatedAt  time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// TouchpointBatchRequest represents a batch of touchpoints to record
+type TouchpointBatchRequest struct {
+	Touchpoints []Touchpoint `json:"touchpoints" validate:"required,dive"`
+}
+
+// AttributionRequest represents a request to attribute purchases to a
+// campaign
+type AttributionRequest struct {
+	Model        string    // last_touch (default), first_touch, linear, time_decay or position_based
+	LookbackDays int       // Touchpoints up to this many days before a purchase count, default 30
+	HalfLifeDays float64   // Time decay half-life, default 7
+	DateRange    DateRange // Purchases attributed, by default from the campaign start to now
+}
+
+// CampaignAttribution holds the purchase revenue credited to a campaign by an
+// attribution model over a date range
+type CampaignAttribution struct {
+	CampaignID            string    `json:"campaign_id"`
+	Model                 string    `json:"model"` // last_touch, first_touch, linear, time_decay, position_based
+	LookbackDays          int       `json:"lookback_days"`
+	StartDate             time.Time `json:"start_date"`
+	EndDate               time.Time `json:"end_date"`
+	TouchedPurchases      int       `json:"touched_purchases"`      // Purchases with a touchpoint of the campaign in their lookback window
+	AttributedConversions float64   `json:"attributed_conversions"` // Fractional purchases credited
+	AttributedRevenue     float64   `json:"attributed_revenue"`
+	Cost                  float64   `json:"cost"` // From campaign performance over the range
+	ROAS                  float64   `json:"roas"` // Attributed revenue over cost, zero without cost
+}
+
+// BudgetAllocationRequest represents a request to split a daily budget across
+// campaigns
+type BudgetAllocationRequest struct {
+	TotalBudget float64                    `json:"total_budget" validate:"required"` // Daily spend to allocate
+	Objective   string                     `json:"objective"`                        // revenue (default) or conversions
+	Curve       string                     `json:"curve"`                            // hill (default) or log
+	HistoryDays int                        `json:"history_days"`                     // Days of performance the curves are fitted on, default 90
+	Campaigns   []CampaignBudgetConstraint `json:"campaigns" validate:"dive"`        // Default every campaign with enough performance in the history
+}
+
+// CampaignBudgetConstraint bounds the daily spend of one campaign
+type CampaignBudgetConstraint struct {
+	CampaignID string  `json:"campaign_id" validate:"required"`
+	MinSpend   float64 `json:"min_spend"`
+	MaxSpend   float64 `json:"max_spend"` // Zero for no maximum
+}
+
+// ResponseCurve is a fitted diminishing-returns curve of an outcome against
+// daily spend
+type ResponseCurve struct {
+	Type       string             `json:"type"`       // hill or log
+	Parameters map[string]float64 `json:"parameters"` // hill: max, half_saturation, shape; log: scale, rate
+	RSquared   float64            `json:"r_squared"`
+}
+
+// BudgetAllocation holds the daily spend per campaign that maximizes the
+// projected outcome, and that outcome compared with the current spend
+type BudgetAllocation struct {
+	Objective        string               `json:"objective"`
+	TotalBudget      float64              `json:"total_budget"`
+	Allocated        float64              `json:"allocated"` // Less than the budget when every campaign is at its maximum
+	ProjectedOutcome float64              `json:"projected_outcome"`
+	CurrentSpend     float64              `json:"current_spend"`
+	CurrentOutcome   float64              `json:"current_outcome"` // Projected at the current spend
+	Campaigns        []CampaignAllocation `json:"campaigns"`
+	Skipped          []SkippedCampaign    `json:"skipped,omitempty"` // Unlisted campaigns with too few days with spend
+}
+
+// SkippedCampaign is a campaign left out of a budget allocation because it
+// had too few days with spend to fit a response curve
+type SkippedCampaign struct {
+	CampaignID string `json:"campaign_id"`
+	SpendDays  int    `json:"spend_days"`
+}
+
+// CampaignAllocation is the recommended daily spend of one campaign
+type CampaignAllocation struct {
+	CampaignID       string        `json:"campaign_id"`
+	CurrentSpend     float64       `json:"current_spend"` // Average daily spend over the history
+	RecommendedSpend float64       `json:"recommended_spend"`
+	ProjectedOutcome float64       `json:"projected_outcome"`
+	MarginalReturn   float64       `json:"marginal_return"` // Outcome per extra unit of spend at the recommendation
+	Curve            ResponseCurve `json:"curve"`
+}
+
+// ExperimentRequest represents a request to compare a campaign's variants
+// against its control
+type ExperimentRequest struct {
+	Control             string    // Variant ID, by default the campaign's control variant
+	ConfidenceLevel     float64   // Of the tests and intervals, default 0.95
+	MinDetectableEffect float64   // Relative lift to plan sample sizes for, default 0.1
+	Power               float64   // To plan sample sizes for, default 0.8
+	DateRange           DateRange // Performance compared, by default all of it
+}
+
+// ExperimentAnalysis compares the CTR and conversion rate of each campaign
+// variant with the control
+type ExperimentAnalysis struct {
+	CampaignID          string              `json:"campaign_id"`
+	Control             string              `json:"control"`
+	ConfidenceLevel     float64             `json:"confidence_level"`
+	MinDetectableEffect float64             `json:"min_detectable_effect"`
+	Power               float64             `json:"power"`
+	Variants            []VariantExperiment `json:"variants"`
+}
+
+// VariantExperiment holds the totals of one variant and, except for the
+// control, its tests against the control
+type VariantExperiment struct {
+	VariantID      string          `json:"variant_id"`
+	Name           string          `json:"name"`
+	IsControl      bool            `json:"is_control"`
+	Impressions    int             `json:"impressions"`
+	Clicks         int             `json:"clicks"`
+	Conversions    int             `json:"conversions"`
+	CTR            float64         `json:"ctr"`             // Percent of impressions clicked
+	ConversionRate float64         `json:"conversion_rate"` // Percent of clicks converted
+	CTRTest        *ProportionTest `json:"ctr_test,omitempty"`
+	ConversionTest *ProportionTest `json:"conversion_test,omitempty"`
+}
+
+// ProportionTest compares a variant's rate with the control's
+type ProportionTest struct {
+	ControlRate              float64 `json:"control_rate"` // Percent
+	VariantRate              float64 `json:"variant_rate"` // Percent
+	Difference               float64 `json:"difference"`   // Percentage points
+	RelativeLift             float64 `json:"relative_lift"`
+	ZScore                   float64 `json:"z_score"`
+	PValue                   float64 `json:"p_value"`  // Two-sided
+	CILower                  float64 `json:"ci_lower"` // Of the difference, percentage points
+	CIUpper                  float64 `json:"ci_upper"` // Of the difference, percentage points
+	Significant              bool    `json:"significant"`
+	ProbabilityToBeatControl float64 `json:"probability_to_beat_control"` // Under uniform Beta priors
+	RequiredSampleSize       int     `json:"required_sample_size"`        // Per variant to detect the minimum effect
+	CurrentPower             float64 `json:"current_power"`               // To detect the minimum effect with the smaller sample
+}
+
+// BanditRequest represents a request for the traffic split across a
+// campaign's variants
+type BanditRequest struct {
+	Algorithm string    // thompson (default) or ucb1
+	Metric    string    // Reward per impression: conversions (default) or clicks
+	Batch     int       // Impressions UCB1 plans ahead for, default 1000
+	DateRange DateRange // Performance learned from, by default all of it
+}
+
+// BanditAllocation holds the recommended share of traffic per campaign
+// variant, computed from the performance recorded so far
+type BanditAllocation struct {
+	CampaignID string              `json:"campaign_id"`
+	Algorithm  string              `json:"algorithm"`
+	Metric     string              `json:"metric"`
+	ComputedAt time.Time           `json:"computed_at"`
+	Variants   []VariantAllocation `json:"variants"`
+}
+
+// VariantAllocation is the recommended traffic share of one variant
+type VariantAllocation struct {
+	VariantID            string  `json:"variant_id"`
+	Name                 string  `json:"name"`
+	Impressions          int     `json:"impressions"`
+	Rewards              int     `json:"rewards"`                          // Clicks or conversions
+	RewardRate           float64 `json:"reward_rate"`                      // Percent of impressions
+	UpperConfidenceBound float64 `json:"upper_confidence_bound,omitempty"` // UCB1 only, percent capped at 100
+	Share                float64 `json:"share"`                            // Thompson sampling: probability of being the best variant
+}
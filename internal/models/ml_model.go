@@ -0,0 +1,128 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TrainedModel represents a versioned model fitted from historical data.
+// Only the active version of each model type is used for predictions.
+type TrainedModel struct {
+	ID              primitive.ObjectID     `json:"id" bson:"_id,omitempty"`
+	ModelType       string                 `json:"model_type" bson:"model_type"` // churn, ltv
+	Version         int                    `json:"version" bson:"version"`
+	Status          string                 `json:"status" bson:"status"` // candidate, active, archived
+	TrainingWindow  TrainingWindow         `json:"training_window" bson:"training_window"`
+	Parameters      map[string]interface{} `json:"parameters" bson:"parameters"`
+	Logistic        *LogisticModel         `json:"logistic,omitempty" bson:"logistic,omitempty"`
+	BGNBD           *BGNBDModel            `json:"bgnbd,omitempty" bson:"bgnbd,omitempty"`
+	GammaGamma      *GammaGammaModel       `json:"gamma_gamma,omitempty" bson:"gamma_gamma,omitempty"`
+	Metrics         map[string]float64     `json:"metrics" bson:"metrics"`
+	TrainingSamples int                    `json:"training_samples" bson:"training_samples"`
+	TrainedAt       time.Time              `json:"trained_at" bson:"trained_at"`
+	PromotedAt      *time.Time             `json:"promoted_at,omitempty" bson:"promoted_at,omitempty"` // Last time the model was made active
+}
+
+// TrainingWindow is the range of purchase dates a model was trained on
+type TrainingWindow struct {
+	Start time.Time `json:"start" bson:"start"`
+	End   time.Time `json:"end" bson:"end"`
+}
+
+// LogisticModel holds the weights of a logistic regression over
+// standardized features
+type LogisticModel struct {
+	FeatureNames []string  `json:"feature_names" bson:"feature_names"`
+	Coefficients []float64 `json:"coefficients" bson:"coefficients"`
+	Intercept    float64   `json:"intercept" bson:"intercept"`
+	Means        []float64 `json:"means" bson:"means"` // Feature means used for standardization
+	Stds         []float64 `json:"stds" bson:"stds"`   // Feature standard deviations used for standardization
+}
+
+// BGNBDModel holds the fitted BG/NBD parameters. Purchase rates are gamma
+// distributed with shape R and rate Alpha (per day); dropout probabilities
+// after each purchase are beta distributed with parameters A and B.
+type BGNBDModel struct {
+	R     float64 `json:"r" bson:"r"`
+	Alpha float64 `json:"alpha" bson:"alpha"`
+	A     float64 `json:"a" bson:"a"`
+	B     float64 `json:"b" bson:"b"`
+}
+
+// GammaGammaModel holds the fitted Gamma-Gamma spend parameters
+type GammaGammaModel struct {
+	P     float64 `json:"p" bson:"p"`
+	Q     float64 `json:"q" bson:"q"`
+	Gamma float64 `json:"gamma" bson:"gamma"`
+}
+
+// ChurnTrainingRequest represents a request to train the churn model. A
+// customer is labelled as churned when they make no purchase within
+// WindowDays after CutoffDate.
+type ChurnTrainingRequest struct {
+	CutoffDate *time.Time             `json:"cutoff_date"` // defaults to WindowDays before now
+	WindowDays int                    `json:"window_days"` // defaults to 90
+	Parameters map[string]interface{} `json:"parameters"`  // learning_rate, iterations, l2, holdout_fraction, seed
+}
+
+// LTVTrainingRequest represents a request to fit the BG/NBD and Gamma-Gamma
+// lifetime value models on all purchases
+type LTVTrainingRequest struct {
+	Parameters map[string]interface{} `json:"parameters"` // max_iterations
+}
+
+// EvaluationRequest represents a request to backtest the predictions at a
+// past cutoff date against the purchases that followed it
+type EvaluationRequest struct {
+	CutoffDate         time.Time `json:"cutoff_date"`
+	WindowDays         int       `json:"window_days"`         // defaults to 90
+	Thresholds         []float64 `json:"thresholds"`          // defaults to 0.3, 0.5 and 0.7
+	CalibrationBuckets int       `json:"calibration_buckets"` // defaults to 10
+}
+
+// EvaluationReport compares predictions made at a cutoff date with what
+// actually happened in the window after it
+type EvaluationReport struct {
+	CutoffDate         time.Time              `json:"cutoff_date"`
+	WindowDays         int                    `json:"window_days"`
+	CustomersEvaluated int                    `json:"customers_evaluated"`
+	Churn              ChurnEvaluation        `json:"churn"`
+	NextPurchase       NextPurchaseEvaluation `json:"next_purchase"`
+}
+
+// ChurnEvaluation holds the accuracy of churn probabilities
+type ChurnEvaluation struct {
+	ModelVersion int                 `json:"model_version"` // 0 when the heuristic was used
+	AUC          float64             `json:"auc"`
+	LogLoss      float64             `json:"log_loss"`
+	ChurnRate    float64             `json:"churn_rate"`
+	Thresholds   []ThresholdMetrics  `json:"thresholds"`
+	Calibration  []CalibrationBucket `json:"calibration"`
+}
+
+// ThresholdMetrics holds classification metrics when predicting positive at
+// or above a probability threshold
+type ThresholdMetrics struct {
+	Threshold float64 `json:"threshold"`
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+}
+
+// CalibrationBucket compares the mean predicted probability with the observed
+// positive rate for predictions in [Lower, Upper)
+type CalibrationBucket struct {
+	Lower         float64 `json:"lower"`
+	Upper         float64 `json:"upper"`
+	Count         int     `json:"count"`
+	MeanPredicted float64 `json:"mean_predicted"`
+	ObservedRate  float64 `json:"observed_rate"`
+}
+
+// NextPurchaseEvaluation holds the error of days-until-next-purchase
+// predictions for customers who purchased within the window
+type NextPurchaseEvaluation struct {
+	Customers int     `json:"customers"`
+	MAEDays   float64 `json:"mae_days"`
+}
@@ -27,19 +27,63 @@ func RegisterAnalyticsRoutes(router *gin.Engine, db *mongo.Database, config *con
 		// Customer management
 		protected.POST("/customers", analyticsHandler.CreateCustomer)
 		protected.GET("/customers", analyticsHandler.GetCustomers)
+		protected.GET("/customers/:id/segments", analyticsHandler.GetCustomerSegments)
+		protected.GET("/customers/:id/predictions", analyticsHandler.GetCustomerPredictions)
+		protected.GET("/customers/:id/predictions/trend", analyticsHandler.GetCustomerPredictionTrend)
+		protected.GET("/customers/:id/features", analyticsHandler.GetCustomerFeatures)
+		protected.GET("/customers/:id/recommendations", analyticsHandler.GetCustomerRecommendations)
+
+		// Segment membership
+		protected.GET("/segments/:id/customers", analyticsHandler.GetSegmentCustomers)
+
+		// Products
+		protected.GET("/products/:id/similar", analyticsHandler.GetSimilarProducts)
 
 		// Purchase management
 		protected.POST("/purchases", analyticsHandler.CreatePurchase)
 
+		// Event tracking
+		protected.POST("/events", analyticsHandler.TrackEvents)
+
 		// Campaign management
 		protected.POST("/campaigns", analyticsHandler.CreateCampaign)
 		protected.GET("/campaigns", analyticsHandler.GetCampaigns)
 		protected.POST("/campaigns/performance", analyticsHandler.CreateCampaignPerformance)
+		protected.POST("/campaigns/touchpoints", analyticsHandler.TrackTouchpoints)
+		protected.GET("/campaigns/:id/attribution", analyticsHandler.GetCampaignAttribution)
+		protected.GET("/campaigns/:id/experiment", analyticsHandler.GetCampaignExperiment)
+		protected.GET("/campaigns/:id/allocation", analyticsHandler.GetVariantAllocation)
 
 		// AI Analytics
 		protected.POST("/analytics/segmentation", analyticsHandler.PerformSegmentation)
+		protected.GET("/analytics/segmentation/runs", analyticsHandler.GetSegmentationRuns)
+		protected.GET("/analytics/segmentation/runs/:id", analyticsHandler.GetSegmentationRun)
+		protected.GET("/analytics/segmentation/runs/:id/diff/:other_id", analyticsHandler.DiffSegmentationRuns)
+		protected.GET("/analytics/rfm", analyticsHandler.GetRFMScores)
 		protected.POST("/analytics/prediction", analyticsHandler.PredictCustomerBehavior)
+		protected.POST("/analytics/prediction/batch", analyticsHandler.StartBatchPrediction)
+		protected.GET("/analytics/prediction/jobs/:id", analyticsHandler.GetPredictionJob)
+		protected.POST("/analytics/evaluation", analyticsHandler.EvaluatePredictions)
+		protected.POST("/analytics/basket", analyticsHandler.AnalyzeBaskets)
+		protected.GET("/analytics/forecast", analyticsHandler.GetForecast)
+		protected.POST("/analytics/funnel", analyticsHandler.AnalyzeFunnel)
+		protected.GET("/analytics/cohorts", analyticsHandler.GetCohortRetention)
+		protected.POST("/analytics/anomalies/detect", analyticsHandler.DetectAnomalies)
+		protected.GET("/analytics/anomalies", analyticsHandler.GetAnomalies)
 		protected.POST("/analytics/optimization", analyticsHandler.OptimizeCampaign)
+		protected.POST("/analytics/budget-allocation", analyticsHandler.AllocateBudget)
 		protected.GET("/analytics/dashboard", analyticsHandler.GetDashboard)
+
+		// Feature store
+		protected.POST("/features/compute", analyticsHandler.ComputeCustomerFeatures)
+
+		// Model training
+		protected.POST("/models/churn/train", analyticsHandler.TrainChurnModel)
+		protected.POST("/models/ltv/train", analyticsHandler.TrainLTVModel)
+
+		// Model registry
+		protected.GET("/models/:type/versions", analyticsHandler.GetModelVersions)
+		protected.POST("/models/:type/versions/:version/promote", analyticsHandler.PromoteModel)
+		protected.POST("/models/:type/rollback", analyticsHandler.RollbackModel)
 	}
 }
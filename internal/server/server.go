@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -13,6 +14,7 @@ import (
 
 	"ai-analytics/internal/config"
 	"ai-analytics/internal/database"
+	"ai-analytics/internal/services"
 )
 
 type Server struct {
@@ -30,6 +32,17 @@ func NewServer(config *config.Config) *http.Server {
 		return nil
 	}
 
+	// Batch prediction jobs run in this process, so any still running were
+	// interrupted by the previous shutdown
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	interrupted, err := services.NewAnalyticsService(mongoDB, config).FailInterruptedPredictionJobs(ctx)
+	if err != nil {
+		log.Printf("Failed to recover prediction jobs: %v", err)
+	} else if interrupted > 0 {
+		log.Printf("Marked %d interrupted prediction jobs as failed", interrupted)
+	}
+
 	NewServer := &Server{
 		port:   port,
 		config: config,
@@ -7,7 +7,6 @@ import (
 	"errors"
 	"fmt"
 	"math"
-	"sort"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -125,6 +124,10 @@ func (s *AnalyticsService) updateCustomerMetrics(ctx context.Context, customerID
 // Campaign Analytics Methods
 
 func (s *AnalyticsService) CreateCampaign(ctx context.Context, campaign models.MarketingCampaign) (*models.MarketingCampaign, error) {
+	if err := validateCampaignVariants(campaign.Variants); err != nil {
+		return nil, err
+	}
+
 	campaign.ID = primitive.NewObjectID()
 	campaign.CreatedAt = time.Now()
 	campaign.UpdatedAt = time.Now()
@@ -181,121 +184,7 @@ func (s *AnalyticsService) CreateCampaignPerformance(ctx context.Context, perfor
 
 // AI Analytics Methods
 
-func (s *AnalyticsService) PerformCustomerSegmentation(ctx context.Context, req models.SegmentationRequest) ([]models.CustomerSegment, error) {
-	// Get customer data
-	customers, err := s.GetCustomers(ctx, 1000, 0) // Limit for demo
-	if err != nil {
-		return nil, fmt.Errorf("failed to get customers for segmentation: %w", err)
-	}
-
-	if len(customers) == 0 {
-		return nil, errors.New("no customers found for segmentation")
-	}
-
-	// Simple K-means clustering implementation
-	segments := s.performKMeansSegmentation(customers, req.Features)
-
-	// Save segments to database
-	var savedSegments []models.CustomerSegment
-	for i, segment := range segments {
-		segment.SegmentID = fmt.Sprintf("segment_%d", i+1)
-		segment.ID = primitive.NewObjectID()
-		segment.CreatedAt = time.Now()
-		segment.UpdatedAt = time.Now()
-
-		collection := s.db.Collection("customer_segments")
-		_, err := collection.InsertOne(ctx, segment)
-		if err != nil {
-			continue
-		}
-
-		savedSegments = append(savedSegments, segment)
-	}
-
-	return savedSegments, nil
-}
-
-func (s *AnalyticsService) performKMeansSegmentation(customers []models.Customer, features []string) []models.CustomerSegment {
-	// Simple 3-cluster segmentation based on spending and frequency
-	// High Value: High spending, high frequency
-	// Medium Value: Medium spending, medium frequency
-	// Low Value: Low spending, low frequency
-
-	var highValue, mediumValue, lowValue []models.Customer
-
-	// Calculate thresholds
-	var totalSpents []float64
-	var frequencies []int
-
-	for _, customer := range customers {
-		totalSpents = append(totalSpents, customer.TotalSpent)
-		frequencies = append(frequencies, customer.PurchaseFrequency)
-	}
-
-	sort.Float64s(totalSpents)
-	sort.Ints(frequencies)
-
-	spendThreshold1 := totalSpents[len(totalSpents)/3]
-	spendThreshold2 := totalSpents[2*len(totalSpents)/3]
-	freqThreshold1 := frequencies[len(frequencies)/3]
-	freqThreshold2 := frequencies[2*len(frequencies)/3]
-
-	for _, customer := range customers {
-		score := 0
-		if customer.TotalSpent > spendThreshold2 {
-			score += 2
-		} else if customer.TotalSpent > spendThreshold1 {
-			score += 1
-		}
-
-		if customer.PurchaseFrequency > freqThreshold2 {
-			score += 2
-		} else if customer.PurchaseFrequency > freqThreshold1 {
-			score += 1
-		}
-
-		switch {
-		case score >= 3:
-			highValue = append(highValue, customer)
-		case score >= 1:
-			mediumValue = append(mediumValue, customer)
-		default:
-			lowValue = append(lowValue, customer)
-		}
-	}
-
-	segments := []models.CustomerSegment{
-		{
-			Name:        "High Value Customers",
-			Description: "Customers with high spending and purchase frequency",
-			Size:        len(highValue),
-			Criteria: map[string]interface{}{
-				"min_total_spent":        spendThreshold2,
-				"min_purchase_frequency": freqThreshold2,
-			},
-		},
-		{
-			Name:        "Medium Value Customers",
-			Description: "Customers with medium spending and purchase frequency",
-			Size:        len(mediumValue),
-			Criteria: map[string]interface{}{
-				"min_total_spent":        spendThreshold1,
-				"min_purchase_frequency": freqThreshold1,
-			},
-		},
-		{
-			Name:        "Low Value Customers",
-			Description: "Customers with low spending and purchase frequency",
-			Size:        len(lowValue),
-			Criteria: map[string]interface{}{
-				"max_total_spent":        spendThreshold1,
-				"max_purchase_frequency": freqThreshold1,
-			},
-		},
-	}
-
-	return segments
-}
+var ErrUnsupportedPredictionType = errors.New("unsupported prediction type")
 
 func (s *AnalyticsService) PredictCustomerBehavior(ctx context.Context, req models.PredictionRequest) (*models.PredictionResult, error) {
 	// Get customer data
@@ -306,22 +195,15 @@ func (s *AnalyticsService) PredictCustomerBehavior(ctx context.Context, req mode
 		return nil, fmt.Errorf("customer not found: %w", err)
 	}
 
-	var prediction models.PredictionResult
-	prediction.ID = primitive.NewObjectID()
-	prediction.CustomerID = req.CustomerID
-	prediction.PredictionType = req.PredictionType
-	prediction.CreatedAt = time.Now()
-
-	switch req.PredictionType {
-	case "churn":
-		prediction = s.predictChurn(customer)
-	case "ltv":
-		prediction = s.predictLifetimeValue(customer)
-	case "next_purchase":
-		prediction = s.predictNextPurchase(customer)
-	default:
-		return nil, errors.New("unsupported prediction type")
+	scorer, err := s.newPredictionScorer(ctx, []string{req.PredictionType}, req.HorizonMonths)
+	if err != nil {
+		return nil, err
+	}
+	predictions, err := scorer.score(ctx, []models.Customer{customer})
+	if err != nil {
+		return nil, err
 	}
+	prediction := predictions[0]
 
 	// Save prediction
 	predictionCollection := s.db.Collection("predictions")
@@ -333,11 +215,11 @@ func (s *AnalyticsService) PredictCustomerBehavior(ctx context.Context, req mode
 	return &prediction, nil
 }
 
-func (s *AnalyticsService) predictChurn(customer models.Customer) models.PredictionResult {
+func (s *AnalyticsService) predictChurn(customer models.Customer, now time.Time) models.PredictionResult {
 	// Simple churn prediction based on recency and frequency
 	daysSinceLastPurchase := 0
 	if customer.LastPurchaseDate != nil {
-		daysSinceLastPurchase = int(time.Since(*customer.LastPurchaseDate).Hours() / 24)
+		daysSinceLastPurchase = int(now.Sub(*customer.LastPurchaseDate).Hours() / 24)
 	} else {
 		daysSinceLastPurchase = 365 // No purchases
 	}
@@ -370,42 +252,43 @@ func (s *AnalyticsService) predictChurn(customer models.Customer) models.Predict
 		PredictionType: "churn",
 		Probability:    probability,
 		Confidence:     0.75,
-		CreatedAt:      time.Now(),
+		CreatedAt:      now,
 	}
 }
 
-func (s *AnalyticsService) predictLifetimeValue(customer models.Customer) models.PredictionResult {
-	// Simple LTV prediction: average order value * purchase frequency * estimated lifespan
-	avgOrderValue := customer.TotalSpent / float64(customer.PurchaseFrequency)
-	if customer.PurchaseFrequency == 0 {
-		avgOrderValue = 0
+func (s *AnalyticsService) predictLifetimeValue(customer models.Customer, horizonMonths int, now time.Time) models.PredictionResult {
+	// Simple LTV prediction: average order value * monthly purchase rate * horizon
+	var avgOrderValue float64
+	if customer.PurchaseFrequency > 0 {
+		avgOrderValue = customer.TotalSpent / float64(customer.PurchaseFrequency)
 	}
 
-	estimatedLifespan := 24.0 // months
-	monthlyPurchaseRate := float64(customer.PurchaseFrequency) / 12.0
-
-	ltv := avgOrderValue * monthlyPurchaseRate * estimatedLifespan
+	monthsActive := math.Max(1, now.Sub(customer.RegistrationDate).Hours()/24/daysPerMonth)
+	monthlyPurchaseRate := float64(customer.PurchaseFrequency) / monthsActive
+	expectedPurchases := monthlyPurchaseRate * float64(horizonMonths)
 
 	return models.PredictionResult{
-		CustomerID:     customer.CustomerID,
-		PredictionType: "ltv",
-		Value:          ltv,
-		Confidence:     0.65,
-		CreatedAt:      time.Now(),
+		CustomerID:        customer.CustomerID,
+		PredictionType:    "ltv",
+		Value:             avgOrderValue * expectedPurchases,
+		Confidence:        0.65,
+		HorizonMonths:     horizonMonths,
+		ExpectedPurchases: expectedPurchases,
+		CreatedAt:         now,
 	}
 }
 
-func (s *AnalyticsService) predictNextPurchase(customer models.Customer) models.PredictionResult {
+func (s *AnalyticsService) predictNextPurchase(customer models.Customer, now time.Time) models.PredictionResult {
 	// Predict days until next purchase based on historical frequency
 	daysBetweenPurchases := 30.0 // default
 	if customer.PurchaseFrequency > 1 && customer.LastPurchaseDate != nil {
-		daysSinceRegistration := time.Since(customer.RegistrationDate).Hours() / 24
+		daysSinceRegistration := now.Sub(customer.RegistrationDate).Hours() / 24
 		daysBetweenPurchases = daysSinceRegistration / float64(customer.PurchaseFrequency)
 	}
 
 	daysSinceLastPurchase := 0.0
 	if customer.LastPurchaseDate != nil {
-		daysSinceLastPurchase = time.Since(*customer.LastPurchaseDate).Hours() / 24
+		daysSinceLastPurchase = now.Sub(*customer.LastPurchaseDate).Hours() / 24
 	}
 
 	daysUntilNextPurchase := math.Max(0, daysBetweenPurchases-daysSinceLastPurchase)
@@ -415,7 +298,7 @@ func (s *AnalyticsService) predictNextPurchase(customer models.Customer) models.
 		PredictionType: "next_purchase",
 		Value:          daysUntilNextPurchase,
 		Confidence:     0.60,
-		CreatedAt:      time.Now(),
+		CreatedAt:      now,
 	}
 }
 
@@ -605,5 +488,10 @@ func (s *AnalyticsService) GetAnalyticsDashboard(ctx context.Context, dateRange
 	dashboard["total_campaigns"] = totalCampaigns
 	dashboard["active_campaigns"] = activeCampaigns
 
+	// RFM segment distribution from the latest scoring
+	if rfmCounts, err := s.rfmSegmentCounts(ctx); err == nil && len(rfmCounts) > 0 {
+		dashboard["rfm_segments"] = rfmCounts
+	}
+
 	return dashboard, nil
 }
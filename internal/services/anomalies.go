@@ -0,0 +1,417 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrInvalidAnomalyParameter = errors.New("invalid anomaly detection parameter")
+
+const (
+	anomalySeasonalResidual = "seasonal_residual"
+	anomalyRollingZScore    = "rolling_zscore"
+
+	// revenueSeasonLength is the weekly cycle removed from daily revenue
+	revenueSeasonLength = 7
+)
+
+// AnomalyScore is the expected value of a point and how many standard
+// deviations the actual value lies from it. Scored is false when there was
+// too little history to tell.
+type AnomalyScore struct {
+	Expected float64
+	ZScore   float64
+	Scored   bool
+}
+
+// RollingZScores scores each point of y against the mean and standard
+// deviation of the window points before it. NaN marks missing points, which
+// are neither scored nor used as history; points with fewer than half a
+// window of history are not scored.
+func RollingZScores(y []float64, window int) []AnomalyScore {
+	minPoints := max(window/2, 3)
+	scores := make([]AnomalyScore, len(y))
+	for t, value := range y {
+		if math.IsNaN(value) {
+			continue
+		}
+
+		var history []float64
+		for _, v := range y[max(t-window, 0):t] {
+			if !math.IsNaN(v) {
+				history = append(history, v)
+			}
+		}
+		if len(history) < minPoints {
+			continue
+		}
+
+		mean, std := meanStd(history)
+		scores[t] = AnomalyScore{Expected: mean, Scored: true}
+		if std > 0 {
+			scores[t].ZScore = (value - mean) / std
+		}
+	}
+	return scores
+}
+
+// SeasonalResidualZScores scores each point of y against the window points
+// before it after removing seasonality: the expected value is the level of the
+// last season plus the seasonal index of the point's phase, and the z-score
+// is relative to the spread of the history's residuals. The first window
+// points are not scored; window must be at least two periods.
+func SeasonalResidualZScores(y []float64, period, window int) []AnomalyScore {
+	scores := make([]AnomalyScore, len(y))
+	for t := window; t < len(y); t++ {
+		history := y[t-window : t]
+		mean, _ := meanStd(history)
+
+		seasonal := make([]float64, period)
+		counts := make([]int, period)
+		for i, v := range history {
+			phase := (t - window + i) % period
+			seasonal[phase] += v - mean
+			counts[phase]++
+		}
+		for phase := range seasonal {
+			seasonal[phase] /= float64(counts[phase])
+		}
+
+		var sse float64
+		for i, v := range history {
+			r := v - mean - seasonal[(t-window+i)%period]
+			sse += r * r
+		}
+		std := math.Sqrt(sse / float64(window-period))
+
+		level, _ := meanStd(history[window-period:])
+		expected := level + seasonal[t%period]
+		scores[t] = AnomalyScore{Expected: expected, Scored: true}
+		if std > 0 {
+			scores[t].ZScore = (y[t] - expected) / std
+		}
+	}
+	return scores
+}
+
+// meanStd returns the mean and sample standard deviation of values.
+func meanStd(values []float64) (mean, std float64) {
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+
+	for _, v := range values {
+		std += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(std / float64(len(values)-1))
+}
+
+func anomalySeverity(z, threshold float64) string {
+	switch abs := math.Abs(z); {
+	case abs >= 2*threshold:
+		return "high"
+	case abs >= 1.5*threshold:
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+// anomaliesIn turns the scores of the days from scanFrom on into anomalies,
+// dating index i as start plus i days.
+func anomaliesIn(y []float64, scores []AnomalyScore, scanFrom int, start time.Time, threshold float64, anomaly models.Anomaly) []models.Anomaly {
+	var anomalies []models.Anomaly
+	for t := scanFrom; t < len(scores); t++ {
+		score := scores[t]
+		if !score.Scored || math.Abs(score.ZScore) < threshold {
+			continue
+		}
+
+		anomaly.Date = start.AddDate(0, 0, t)
+		anomaly.Value = y[t]
+		anomaly.Expected = score.Expected
+		anomaly.ZScore = score.ZScore
+		anomaly.Direction = "spike"
+		if score.ZScore < 0 {
+			anomaly.Direction = "drop"
+		}
+		anomaly.Severity = anomalySeverity(score.ZScore, threshold)
+		anomalies = append(anomalies, anomaly)
+	}
+	return anomalies
+}
+
+// DetectAnomalies scans the last days of daily revenue, with its weekly cycle
+// removed, and of each campaign's daily CTR and ROAS for values far from
+// their recent history. Detected anomalies are saved, replacing earlier
+// detections of the same metric and day, and returned.
+func (s *AnalyticsService) DetectAnomalies(ctx context.Context, req models.AnomalyDetectionRequest) ([]models.Anomaly, error) {
+	lookbackDays := req.LookbackDays
+	if lookbackDays == 0 {
+		lookbackDays = 7
+	}
+	if lookbackDays < 0 || lookbackDays > 365 {
+		return nil, fmt.Errorf("%w: lookback_days must be between 1 and 365", ErrInvalidAnomalyParameter)
+	}
+	windowDays := req.WindowDays
+	if windowDays == 0 {
+		windowDays = 28
+	}
+	if windowDays < 2*revenueSeasonLength {
+		return nil, fmt.Errorf("%w: window_days must be at least %d", ErrInvalidAnomalyParameter, 2*revenueSeasonLength)
+	}
+	threshold := req.Threshold
+	if threshold == 0 {
+		threshold = 3
+	}
+	if threshold < 0 {
+		return nil, fmt.Errorf("%w: threshold must be positive", ErrInvalidAnomalyParameter)
+	}
+
+	// Scan whole days up to the start of today, each against the window before it
+	now := time.Now()
+	today := time.Date(now.UTC().Year(), now.UTC().Month(), now.UTC().Day(), 0, 0, 0, 0, time.UTC)
+	start := today.AddDate(0, 0, -(windowDays + lookbackDays))
+	days := windowDays + lookbackDays
+
+	revenueAnomalies, err := s.revenueAnomalies(ctx, start, days, windowDays, threshold, now)
+	if err != nil {
+		return nil, err
+	}
+	campaignAnomalies, err := s.campaignAnomalies(ctx, start, days, windowDays, threshold, now)
+	if err != nil {
+		return nil, err
+	}
+	anomalies := append(append([]models.Anomaly{}, revenueAnomalies...), campaignAnomalies...)
+
+	if len(anomalies) > 0 {
+		writes := make([]mongo.WriteModel, len(anomalies))
+		for i, anomaly := range anomalies {
+			writes[i] = mongo.NewReplaceOneModel().
+				SetFilter(bson.M{"metric": anomaly.Metric, "campaign_id": anomaly.CampaignID, "date": anomaly.Date}).
+				SetReplacement(anomaly).
+				SetUpsert(true)
+		}
+		if _, err := s.db.Collection("anomalies").BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+			return nil, fmt.Errorf("failed to save anomalies: %w", err)
+		}
+	}
+
+	sort.SliceStable(anomalies, func(a, b int) bool {
+		return anomalies[a].Date.After(anomalies[b].Date)
+	})
+	return anomalies, nil
+}
+
+// revenueAnomalies scores daily revenue over the days from start. Days before
+// the first purchase are not used as history.
+func (s *AnalyticsService) revenueAnomalies(ctx context.Context, start time.Time, days, windowDays int, threshold float64, now time.Time) ([]models.Anomaly, error) {
+	pipeline := []bson.M{
+		{"$match": bson.M{"purchase_date": bson.M{"$gte": start, "$lt": start.AddDate(0, 0, days)}}},
+		{"$group": bson.M{
+			"_id":     bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$purchase_date"}},
+			"revenue": bson.M{"$sum": "$amount"},
+		}},
+	}
+	cursor, err := s.db.Collection("purchases").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate daily revenue: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	revenue := make([]float64, days)
+	firstDay := days
+	for cursor.Next(ctx) {
+		var row struct {
+			Day     string  `bson:"_id"`
+			Revenue float64 `bson:"revenue"`
+		}
+		if err := cursor.Decode(&row); err != nil {
+			return nil, fmt.Errorf("failed to decode daily revenue: %w", err)
+		}
+		index, ok := dayIndex(row.Day, start, days)
+		if !ok {
+			continue
+		}
+		revenue[index] = row.Revenue
+		firstDay = min(firstDay, index)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read daily revenue: %w", err)
+	}
+	if firstDay == days {
+		return nil, nil
+	}
+
+	y := revenue[firstDay:]
+	scores := SeasonalResidualZScores(y, revenueSeasonLength, windowDays)
+	return anomaliesIn(y, scores, max(windowDays-firstDay, 0), start.AddDate(0, 0, firstDay), threshold, models.Anomaly{
+		Metric:     "revenue",
+		Method:     anomalySeasonalResidual,
+		DetectedAt: now,
+	}), nil
+}
+
+// campaignAnomalies scores each campaign's daily CTR and ROAS over the days
+// from start, CTR in percent as on campaign performance. Days without
+// impressions or cost have no CTR or ROAS.
+func (s *AnalyticsService) campaignAnomalies(ctx context.Context, start time.Time, days, windowDays int, threshold float64, now time.Time) ([]models.Anomaly, error) {
+	pipeline := []bson.M{
+		{"$match": bson.M{"date": bson.M{"$gte": start, "$lt": start.AddDate(0, 0, days)}}},
+		{"$group": bson.M{
+			"_id": bson.M{
+				"campaign_id": "$campaign_id",
+				"day":         bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$date"}},
+			},
+			"impressions": bson.M{"$sum": "$impressions"},
+			"clicks":      bson.M{"$sum": "$clicks"},
+			"revenue":     bson.M{"$sum": "$revenue"},
+			"cost":        bson.M{"$sum": "$cost"},
+		}},
+	}
+	cursor, err := s.db.Collection("campaign_performance").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate daily campaign performance: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	ctr := make(map[string][]float64)
+	roas := make(map[string][]float64)
+	for cursor.Next(ctx) {
+		var row struct {
+			ID struct {
+				CampaignID string `bson:"campaign_id"`
+				Day        string `bson:"day"`
+			} `bson:"_id"`
+			Impressions float64 `bson:"impressions"`
+			Clicks      float64 `bson:"clicks"`
+			Revenue     float64 `bson:"revenue"`
+			Cost        float64 `bson:"cost"`
+		}
+		if err := cursor.Decode(&row); err != nil {
+			return nil, fmt.Errorf("failed to decode daily campaign performance: %w", err)
+		}
+		index, ok := dayIndex(row.ID.Day, start, days)
+		if !ok {
+			continue
+		}
+
+		campaignID := row.ID.CampaignID
+		if ctr[campaignID] == nil {
+			ctr[campaignID] = missingSeries(days)
+			roas[campaignID] = missingSeries(days)
+		}
+		if row.Impressions > 0 {
+			ctr[campaignID][index] = row.Clicks / row.Impressions * 100
+		}
+		if row.Cost > 0 {
+			roas[campaignID][index] = row.Revenue / row.Cost
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read daily campaign performance: %w", err)
+	}
+
+	campaignIDs := make([]string, 0, len(ctr))
+	for campaignID := range ctr {
+		campaignIDs = append(campaignIDs, campaignID)
+	}
+	sort.Strings(campaignIDs)
+
+	var anomalies []models.Anomaly
+	for _, campaignID := range campaignIDs {
+		for _, metric := range []struct {
+			name   string
+			series []float64
+		}{{"ctr", ctr[campaignID]}, {"roas", roas[campaignID]}} {
+			scores := RollingZScores(metric.series, windowDays)
+			anomalies = append(anomalies, anomaliesIn(metric.series, scores, windowDays, start, threshold, models.Anomaly{
+				Metric:     metric.name,
+				CampaignID: campaignID,
+				Method:     anomalyRollingZScore,
+				DetectedAt: now,
+			})...)
+		}
+	}
+	return anomalies, nil
+}
+
+// dayIndex returns the number of days from start to a YYYY-MM-DD day, and
+// whether it falls within the given number of days.
+func dayIndex(day string, start time.Time, days int) (int, bool) {
+	date, err := time.Parse("2006-01-02", day)
+	if err != nil {
+		return 0, false
+	}
+	index := int(date.Sub(start).Hours() / 24)
+	return index, index >= 0 && index < days
+}
+
+func missingSeries(days int) []float64 {
+	series := make([]float64, days)
+	for i := range series {
+		series[i] = math.NaN()
+	}
+	return series
+}
+
+// GetAnomalies returns a page of saved anomalies, most recent first, along
+// with the total number matching the filters. Empty filters match anything.
+func (s *AnalyticsService) GetAnomalies(ctx context.Context, metric, campaignID, severity string, dateRange models.DateRange, limit, offset int) ([]models.Anomaly, int64, error) {
+	filter := bson.M{}
+	if metric != "" {
+		filter["metric"] = metric
+	}
+	if campaignID != "" {
+		filter["campaign_id"] = campaignID
+	}
+	if severity != "" {
+		filter["severity"] = severity
+	}
+	date := bson.M{}
+	if !dateRange.StartDate.IsZero() {
+		date["$gte"] = dateRange.StartDate
+	}
+	if !dateRange.EndDate.IsZero() {
+		date["$lte"] = dateRange.EndDate
+	}
+	if len(date) > 0 {
+		filter["date"] = date
+	}
+
+	collection := s.db.Collection("anomalies")
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count anomalies: %w", err)
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "date", Value: -1}, {Key: "metric", Value: 1}}).
+		SetLimit(int64(limit)).
+		SetSkip(int64(offset))
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get anomalies: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	anomalies := []models.Anomaly{}
+	if err = cursor.All(ctx, &anomalies); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode anomalies: %w", err)
+	}
+
+	return anomalies, total, nil
+}
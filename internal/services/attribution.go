@@ -0,0 +1,290 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrCampaignNotFound            = errors.New("campaign not found")
+	ErrInvalidTouchpoints          = errors.New("invalid touchpoints")
+	ErrInvalidAttributionParameter = errors.New("invalid attribution parameter")
+)
+
+const (
+	// maxTouchpointBatchSize bounds the number of touchpoints recorded per request
+	maxTouchpointBatchSize = 1000
+
+	// attributionBatchSize is the number of customers whose touchpoints and
+	// purchases are loaded per round trip
+	attributionBatchSize = 500
+)
+
+var attributionModels = map[string]bool{
+	"last_touch":     true,
+	"first_touch":    true,
+	"linear":         true,
+	"time_decay":     true,
+	"position_based": true,
+}
+
+// TrackTouchpoints records a batch of campaign touchpoints and returns how
+// many were saved. Touchpoints without a timestamp are stamped with the
+// current time.
+func (s *AnalyticsService) TrackTouchpoints(ctx context.Context, touchpoints []models.Touchpoint) (int, error) {
+	if len(touchpoints) == 0 {
+		return 0, fmt.Errorf("%w: no touchpoints given", ErrInvalidTouchpoints)
+	}
+	if len(touchpoints) > maxTouchpointBatchSize {
+		return 0, fmt.Errorf("%w: at most %d touchpoints per batch", ErrInvalidTouchpoints, maxTouchpointBatchSize)
+	}
+
+	now := time.Now()
+	docs := make([]interface{}, len(touchpoints))
+	for i, touchpoint := range touchpoints {
+		touchpoint.ID = primitive.NewObjectID()
+		if touchpoint.Timestamp.IsZero() {
+			touchpoint.Timestamp = now
+		}
+		touchpoint.CreatedAt = now
+		docs[i] = touchpoint
+	}
+
+	if _, err := s.db.Collection("touchpoints").InsertMany(ctx, docs, options.InsertMany().SetOrdered(false)); err != nil {
+		return 0, fmt.Errorf("failed to save touchpoints: %w", err)
+	}
+	return len(docs), nil
+}
+
+// AttributionWeights splits the credit for a purchase between the touchpoints
+// that preceded it, given in chronological order. The weights sum to one:
+//   - last_touch and first_touch credit a single touchpoint
+//   - linear credits every touchpoint equally
+//   - time_decay halves a touchpoint's credit for every halfLife it came
+//     before the purchase
+//   - position_based credits 40% each to the first and last touchpoints and
+//     splits the remaining 20% across those in between
+func AttributionWeights(model string, touchpoints []time.Time, purchase time.Time, halfLife time.Duration) []float64 {
+	n := len(touchpoints)
+	weights := make([]float64, n)
+	if n == 0 {
+		return weights
+	}
+
+	switch model {
+	case "first_touch":
+		weights[0] = 1
+	case "linear":
+		for i := range weights {
+			weights[i] = 1 / float64(n)
+		}
+	case "time_decay":
+		var total float64
+		for i, touchpoint := range touchpoints {
+			weights[i] = math.Exp2(-purchase.Sub(touchpoint).Hours() / halfLife.Hours())
+			total += weights[i]
+		}
+		for i := range weights {
+			weights[i] /= total
+		}
+	case "position_based":
+		switch n {
+		case 1:
+			weights[0] = 1
+		case 2:
+			weights[0], weights[1] = 0.5, 0.5
+		default:
+			weights[0], weights[n-1] = 0.4, 0.4
+			for i := 1; i < n-1; i++ {
+				weights[i] = 0.2 / float64(n-2)
+			}
+		}
+	default:
+		weights[n-1] = 1
+	}
+	return weights
+}
+
+// AttributeCampaign credits the campaign with its share of every purchase in
+// the date range, split by the attribution model across all the campaign
+// touchpoints the customer had in the lookback window before the purchase,
+// and compares the attributed revenue with the campaign's recorded cost.
+func (s *AnalyticsService) AttributeCampaign(ctx context.Context, campaignID string, req models.AttributionRequest) (*models.CampaignAttribution, error) {
+	model := req.Model
+	if model == "" {
+		model = "last_touch"
+	}
+	if !attributionModels[model] {
+		return nil, fmt.Errorf("%w: unsupported model %s", ErrInvalidAttributionParameter, model)
+	}
+	lookbackDays := req.LookbackDays
+	if lookbackDays == 0 {
+		lookbackDays = 30
+	}
+	if lookbackDays < 0 {
+		return nil, fmt.Errorf("%w: lookback_days must be positive", ErrInvalidAttributionParameter)
+	}
+	halfLifeDays := req.HalfLifeDays
+	if halfLifeDays == 0 {
+		halfLifeDays = 7
+	}
+	if halfLifeDays < 0 {
+		return nil, fmt.Errorf("%w: half_life_days must be positive", ErrInvalidAttributionParameter)
+	}
+
+	var campaign models.MarketingCampaign
+	err := s.db.Collection("campaigns").FindOne(ctx, bson.M{"campaign_id": campaignID}).Decode(&campaign)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrCampaignNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get campaign: %w", err)
+	}
+
+	start, end := req.DateRange.StartDate, req.DateRange.EndDate
+	if start.IsZero() {
+		start = campaign.StartDate
+	}
+	if end.IsZero() {
+		end = time.Now()
+	}
+	if !start.Before(end) {
+		return nil, fmt.Errorf("%w: start_date must be before end_date", ErrInvalidAttributionParameter)
+	}
+
+	lookback := time.Duration(lookbackDays) * 24 * time.Hour
+	halfLife := time.Duration(halfLifeDays * 24 * float64(time.Hour))
+	touchpointWindow := bson.M{"$gte": start.Add(-lookback), "$lte": end}
+
+	attribution := &models.CampaignAttribution{
+		CampaignID:   campaignID,
+		Model:        model,
+		LookbackDays: lookbackDays,
+		StartDate:    start,
+		EndDate:      end,
+	}
+
+	customerIDs, err := s.db.Collection("touchpoints").Distinct(ctx, "customer_id",
+		bson.M{"campaign_id": campaignID, "timestamp": touchpointWindow})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get touched customers: %w", err)
+	}
+
+	for batchStart := 0; batchStart < len(customerIDs); batchStart += attributionBatchSize {
+		batch := customerIDs[batchStart:min(batchStart+attributionBatchSize, len(customerIDs))]
+
+		touchpoints, err := s.customerTouchpoints(ctx, batch, touchpointWindow)
+		if err != nil {
+			return nil, err
+		}
+
+		cursor, err := s.db.Collection("purchases").Find(ctx, bson.M{
+			"customer_id":   bson.M{"$in": batch},
+			"purchase_date": bson.M{"$gte": start, "$lte": end},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get purchases: %w", err)
+		}
+		var purchases []models.Purchase
+		err = cursor.All(ctx, &purchases)
+		cursor.Close(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode purchases: %w", err)
+		}
+
+		for _, purchase := range purchases {
+			var times []time.Time
+			var fromCampaign []bool
+			touched := false
+			for _, touchpoint := range touchpoints[purchase.CustomerID] {
+				if touchpoint.Timestamp.After(purchase.PurchaseDate) || touchpoint.Timestamp.Before(purchase.PurchaseDate.Add(-lookback)) {
+					continue
+				}
+				times = append(times, touchpoint.Timestamp)
+				fromCampaign = append(fromCampaign, touchpoint.CampaignID == campaignID)
+				touched = touched || touchpoint.CampaignID == campaignID
+			}
+			if !touched {
+				continue
+			}
+
+			var credit float64
+			for i, weight := range AttributionWeights(model, times, purchase.PurchaseDate, halfLife) {
+				if fromCampaign[i] {
+					credit += weight
+				}
+			}
+			attribution.TouchedPurchases++
+			attribution.AttributedConversions += credit
+			attribution.AttributedRevenue += credit * purchase.Amount
+		}
+	}
+
+	cost, err := s.campaignCost(ctx, campaignID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	attribution.Cost = cost
+	if cost > 0 {
+		attribution.ROAS = attribution.AttributedRevenue / cost
+	}
+
+	return attribution, nil
+}
+
+// customerTouchpoints returns the touchpoints of the customers within the
+// timestamp window, across all campaigns, in chronological order per customer.
+func (s *AnalyticsService) customerTouchpoints(ctx context.Context, customerIDs []interface{}, window bson.M) (map[string][]models.Touchpoint, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}})
+	cursor, err := s.db.Collection("touchpoints").Find(ctx, bson.M{
+		"customer_id": bson.M{"$in": customerIDs},
+		"timestamp":   window,
+	}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get touchpoints: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	touchpoints := make(map[string][]models.Touchpoint)
+	for cursor.Next(ctx) {
+		var touchpoint models.Touchpoint
+		if err := cursor.Decode(&touchpoint); err != nil {
+			return nil, fmt.Errorf("failed to decode touchpoint: %w", err)
+		}
+		touchpoints[touchpoint.CustomerID] = append(touchpoints[touchpoint.CustomerID], touchpoint)
+	}
+	return touchpoints, cursor.Err()
+}
+
+// campaignCost sums the cost recorded in the campaign's performance between
+// start and end.
+func (s *AnalyticsService) campaignCost(ctx context.Context, campaignID string, start, end time.Time) (float64, error) {
+	pipeline := []bson.M{
+		{"$match": bson.M{"campaign_id": campaignID, "date": bson.M{"$gte": start, "$lte": end}}},
+		{"$group": bson.M{"_id": nil, "cost": bson.M{"$sum": "$cost"}}},
+	}
+	cursor, err := s.db.Collection("campaign_performance").Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, fmt.Errorf("failed to aggregate campaign cost: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var total struct {
+		Cost float64 `bson:"cost"`
+	}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&total); err != nil {
+			return 0, fmt.Errorf("failed to decode campaign cost: %w", err)
+		}
+	}
+	return total.Cost, cursor.Err()
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+var ErrInvalidBanditParameter = errors.New("invalid bandit parameter")
+
+const (
+	thompsonSamplingDraws = 10000
+	maxBanditBatch        = 100000
+)
+
+// ThompsonSamplingShares returns the share of traffic Thompson sampling gives
+// each arm, which is the probability that its reward rate is the highest under
+// uniform Beta priors, estimated from draws samples of every arm's posterior.
+func ThompsonSamplingShares(rng *rand.Rand, rewards, trials []int, draws int) []float64 {
+	shares := make([]float64, len(trials))
+	for d := 0; d < draws; d++ {
+		best, bestValue := 0, -1.0
+		for i := range trials {
+			value := sampleBeta(rng, float64(rewards[i]+1), float64(trials[i]-rewards[i]+1))
+			if value > bestValue {
+				best, bestValue = i, value
+			}
+		}
+		shares[best]++
+	}
+	for i := range shares {
+		shares[i] /= float64(draws)
+	}
+	return shares
+}
+
+// UCB1Shares plans the next batch of pulls with UCB1 and returns the share of
+// the batch per arm, along with each arm's upper confidence bound before the
+// batch. Every pull goes to the arm with the highest mean + sqrt(2 ln N / n),
+// untried arms first, and leaves the arm's mean reward unchanged. Bounds of
+// untried arms are reported as +Inf.
+func UCB1Shares(rewards, trials []int, batch int) (shares, bounds []float64) {
+	n := make([]float64, len(trials))
+	mean := make([]float64, len(trials))
+	var total float64
+	for i := range trials {
+		n[i] = float64(trials[i])
+		if trials[i] > 0 {
+			mean[i] = float64(rewards[i]) / n[i]
+		}
+		total += n[i]
+	}
+	bound := func(i int) float64 {
+		if n[i] == 0 {
+			return math.Inf(1)
+		}
+		return mean[i] + math.Sqrt(2*math.Log(total)/n[i])
+	}
+
+	bounds = make([]float64, len(trials))
+	for i := range trials {
+		bounds[i] = bound(i)
+	}
+
+	shares = make([]float64, len(trials))
+	for b := 0; b < batch; b++ {
+		best := 0
+		for i := 1; i < len(trials); i++ {
+			if bound(i) > bound(best) {
+				best = i
+			}
+		}
+		shares[best]++
+		n[best]++
+		total++
+	}
+	for i := range shares {
+		shares[i] /= float64(batch)
+	}
+	return shares, bounds
+}
+
+// RecommendVariantAllocation recommends how to split the campaign's traffic
+// across its variants with a multi-armed bandit over the reward per
+// impression recorded in its performance. It reads the performance on every
+// call, so the split follows new performance as it is recorded.
+func (s *AnalyticsService) RecommendVariantAllocation(ctx context.Context, campaignID string, req models.BanditRequest) (*models.BanditAllocation, error) {
+	algorithm := req.Algorithm
+	if algorithm == "" {
+		algorithm = "thompson"
+	}
+	if algorithm != "thompson" && algorithm != "ucb1" {
+		return nil, fmt.Errorf("%w: unsupported algorithm %s", ErrInvalidBanditParameter, algorithm)
+	}
+	metric := req.Metric
+	if metric == "" {
+		metric = "conversions"
+	}
+	if metric != "conversions" && metric != "clicks" {
+		return nil, fmt.Errorf("%w: unsupported metric %s", ErrInvalidBanditParameter, metric)
+	}
+	batch := req.Batch
+	if batch == 0 {
+		batch = 1000
+	}
+	if batch < 0 || batch > maxBanditBatch {
+		return nil, fmt.Errorf("%w: batch must be between 1 and %d", ErrInvalidBanditParameter, maxBanditBatch)
+	}
+
+	variants, err := s.campaignVariantTotals(ctx, campaignID, req.DateRange)
+	if err != nil {
+		return nil, err
+	}
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("%w: campaign %s has no variants", ErrInvalidBanditParameter, campaignID)
+	}
+
+	rewards := make([]int, len(variants))
+	trials := make([]int, len(variants))
+	for i, variant := range variants {
+		trials[i] = variant.impressions
+		rewards[i] = variant.conversions
+		if metric == "clicks" {
+			rewards[i] = variant.clicks
+		}
+		rewards[i] = min(rewards[i], trials[i])
+	}
+
+	var shares, bounds []float64
+	if algorithm == "ucb1" {
+		shares, bounds = UCB1Shares(rewards, trials, batch)
+	} else {
+		rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+		shares = ThompsonSamplingShares(rng, rewards, trials, thompsonSamplingDraws)
+	}
+
+	allocation := &models.BanditAllocation{
+		CampaignID: campaignID,
+		Algorithm:  algorithm,
+		Metric:     metric,
+		ComputedAt: time.Now(),
+		Variants:   make([]models.VariantAllocation, len(variants)),
+	}
+	for i, variant := range variants {
+		variantAllocation := models.VariantAllocation{
+			VariantID:   variant.variantID,
+			Name:        variant.name,
+			Impressions: trials[i],
+			Rewards:     rewards[i],
+			Share:       shares[i],
+		}
+		if trials[i] > 0 {
+			variantAllocation.RewardRate = float64(rewards[i]) / float64(trials[i]) * 100
+		}
+		if bounds != nil {
+			variantAllocation.UpperConfidenceBound = math.Min(bounds[i], 1) * 100
+		}
+		allocation.Variants[i] = variantAllocation
+	}
+
+	return allocation, nil
+}
@@ -0,0 +1,421 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrInvalidBasketParameter = errors.New("invalid basket analysis parameter")
+
+// maxBasketItemsetSize bounds the itemset size, since the number of candidate
+// itemsets grows combinatorially with it
+const maxBasketItemsetSize = 5
+
+// basketItemFields maps each analysis level to the purchase field it groups
+var basketItemFields = map[string]string{
+	"product":  "$product_id",
+	"category": "$category",
+}
+
+// basketKeys maps each basket definition to the group key of the purchases
+// that form one basket
+var basketKeys = map[string]interface{}{
+	"customer_day": bson.M{
+		"customer_id": "$customer_id",
+		"day":         bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$purchase_date"}},
+	},
+	"customer_week": bson.M{
+		"customer_id": "$customer_id",
+		"week":        bson.M{"$dateToString": bson.M{"format": "%G-%V", "date": "$purchase_date"}},
+	},
+	"customer": "$customer_id",
+}
+
+// FrequentItemset is a set of items, in sorted order, found together in at
+// least the minimum number of baskets
+type FrequentItemset struct {
+	Items []string
+	Count int
+}
+
+// AnalyzeBaskets mines association rules between the products or categories
+// bought together in the same basket.
+func (s *AnalyticsService) AnalyzeBaskets(ctx context.Context, req models.BasketAnalysisRequest) (*models.BasketAnalysisResult, error) {
+	level := req.Level
+	if level == "" {
+		level = "product"
+	}
+	itemField, ok := basketItemFields[level]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported level %s", ErrInvalidBasketParameter, level)
+	}
+	basket := req.Basket
+	if basket == "" {
+		basket = "customer_day"
+	}
+	basketKey, ok := basketKeys[basket]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported basket %s", ErrInvalidBasketParameter, basket)
+	}
+
+	minSupport := req.MinSupport
+	if minSupport == 0 {
+		minSupport = 0.01
+	}
+	if minSupport < 0 || minSupport > 1 {
+		return nil, fmt.Errorf("%w: min_support must be between 0 and 1", ErrInvalidBasketParameter)
+	}
+	minConfidence := req.MinConfidence
+	if minConfidence == 0 {
+		minConfidence = 0.1
+	}
+	if minConfidence < 0 || minConfidence > 1 {
+		return nil, fmt.Errorf("%w: min_confidence must be between 0 and 1", ErrInvalidBasketParameter)
+	}
+	minLift := req.MinLift
+	if minLift == 0 {
+		minLift = 1
+	}
+	if minLift < 0 {
+		return nil, fmt.Errorf("%w: min_lift must be positive", ErrInvalidBasketParameter)
+	}
+	maxSize := req.MaxItemsetSize
+	if maxSize == 0 {
+		maxSize = 3
+	}
+	if maxSize < 2 || maxSize > maxBasketItemsetSize {
+		return nil, fmt.Errorf("%w: max_itemset_size must be between 2 and %d", ErrInvalidBasketParameter, maxBasketItemsetSize)
+	}
+	maxRules := req.MaxRules
+	if maxRules == 0 {
+		maxRules = 100
+	}
+	if maxRules < 0 {
+		return nil, fmt.Errorf("%w: max_rules must be positive", ErrInvalidBasketParameter)
+	}
+
+	match := bson.M{itemField[1:]: bson.M{"$exists": true, "$ne": ""}}
+	if req.DateRange != nil {
+		purchaseDate := bson.M{}
+		if !req.DateRange.StartDate.IsZero() {
+			purchaseDate["$gte"] = req.DateRange.StartDate
+		}
+		if !req.DateRange.EndDate.IsZero() {
+			purchaseDate["$lte"] = req.DateRange.EndDate
+		}
+		if len(purchaseDate) > 0 {
+			match["purchase_date"] = purchaseDate
+		}
+	}
+
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$group": bson.M{
+			"_id":   basketKey,
+			"items": bson.M{"$addToSet": itemField},
+		}},
+	}
+	cursor, err := s.db.Collection("purchases").Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate baskets: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var baskets [][]string
+	for cursor.Next(ctx) {
+		var doc struct {
+			Items []string `bson:"items"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("failed to decode basket: %w", err)
+		}
+		baskets = append(baskets, doc.Items)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read baskets: %w", err)
+	}
+
+	itemsets := Apriori(baskets, minSupport, maxSize)
+	rules := AssociationRules(itemsets, len(baskets), minConfidence, minLift)
+	if len(rules) > maxRules {
+		rules = rules[:maxRules]
+	}
+
+	return &models.BasketAnalysisResult{
+		Level:            level,
+		Basket:           basket,
+		Baskets:          len(baskets),
+		FrequentItemsets: len(itemsets),
+		Rules:            rules,
+	}, nil
+}
+
+// Apriori returns the itemsets of up to maxSize items contained in at least
+// minSupport of the baskets, smallest first. Items within a basket must be
+// distinct.
+func Apriori(baskets [][]string, minSupport float64, maxSize int) []FrequentItemset {
+	minCount := max(int(math.Ceil(minSupport*float64(len(baskets)))), 1)
+
+	itemCounts := make(map[string]int)
+	for _, basket := range baskets {
+		for _, item := range basket {
+			itemCounts[item]++
+		}
+	}
+
+	// Work on sorted indexes of the frequent items, so itemsets are sorted
+	// int slices and their items come out in sorted order
+	var items []string
+	for item, count := range itemCounts {
+		if count >= minCount {
+			items = append(items, item)
+		}
+	}
+	sort.Strings(items)
+	index := make(map[string]int, len(items))
+	for i, item := range items {
+		index[item] = i
+	}
+
+	var encoded [][]int
+	for _, basket := range baskets {
+		var row []int
+		for _, item := range basket {
+			if i, ok := index[item]; ok {
+				row = append(row, i)
+			}
+		}
+		if len(row) >= 2 {
+			sort.Ints(row)
+			encoded = append(encoded, row)
+		}
+	}
+
+	itemsets := make([]FrequentItemset, 0, len(items))
+	current := make([][]int, len(items))
+	for i, item := range items {
+		itemsets = append(itemsets, FrequentItemset{Items: []string{item}, Count: itemCounts[item]})
+		current[i] = []int{i}
+	}
+
+	for size := 2; size <= maxSize && len(current) > 1; size++ {
+		candidates := aprioriCandidates(current)
+		if len(candidates) == 0 {
+			break
+		}
+
+		counts := make(map[string]int, len(candidates))
+		for _, candidate := range candidates {
+			counts[itemsetKey(candidate)] = 0
+		}
+		for _, row := range encoded {
+			if len(row) < size {
+				continue
+			}
+			// Enumerate the basket's subsets when there are fewer of them than
+			// candidates, otherwise test each candidate
+			if binomial(len(row), size) <= len(candidates) {
+				forEachCombination(row, size, func(subset []int) {
+					key := itemsetKey(subset)
+					if _, ok := counts[key]; ok {
+						counts[key]++
+					}
+				})
+				continue
+			}
+			for _, candidate := range candidates {
+				if containsSorted(row, candidate) {
+					counts[itemsetKey(candidate)]++
+				}
+			}
+		}
+
+		current = current[:0:0]
+		for _, candidate := range candidates {
+			count := counts[itemsetKey(candidate)]
+			if count < minCount {
+				continue
+			}
+			current = append(current, candidate)
+			itemset := FrequentItemset{Items: make([]string, len(candidate)), Count: count}
+			for j, i := range candidate {
+				itemset.Items[j] = items[i]
+			}
+			itemsets = append(itemsets, itemset)
+		}
+	}
+
+	return itemsets
+}
+
+// aprioriCandidates joins the frequent itemsets of one size that share all but
+// their last item into candidates one item larger, dropping those with an
+// infrequent subset. The itemsets must be in lexicographic order.
+func aprioriCandidates(frequent [][]int) [][]int {
+	known := make(map[string]bool, len(frequent))
+	for _, itemset := range frequent {
+		known[itemsetKey(itemset)] = true
+	}
+
+	var candidates [][]int
+	for a := 0; a < len(frequent); a++ {
+		prefix := frequent[a][:len(frequent[a])-1]
+		for b := a + 1; b < len(frequent); b++ {
+			if !equalInts(prefix, frequent[b][:len(frequent[b])-1]) {
+				break
+			}
+			candidate := append(append([]int(nil), frequent[a]...), frequent[b][len(frequent[b])-1])
+
+			pruned := false
+			subset := make([]int, 0, len(candidate)-1)
+			for skip := range candidate[:len(candidate)-2] {
+				subset = append(append(subset[:0], candidate[:skip]...), candidate[skip+1:]...)
+				if !known[itemsetKey(subset)] {
+					pruned = true
+					break
+				}
+			}
+			if !pruned {
+				candidates = append(candidates, candidate)
+			}
+		}
+	}
+	return candidates
+}
+
+// AssociationRules derives the rules antecedent => consequent from every
+// split of the frequent itemsets, keeping those that reach the minimum
+// confidence and lift. Rules are sorted by lift, then confidence, then count.
+func AssociationRules(itemsets []FrequentItemset, baskets int, minConfidence, minLift float64) []models.AssociationRule {
+	counts := make(map[string]int, len(itemsets))
+	for _, itemset := range itemsets {
+		counts[strings.Join(itemset.Items, "\x00")] = itemset.Count
+	}
+
+	rules := []models.AssociationRule{}
+	for _, itemset := range itemsets {
+		n := len(itemset.Items)
+		if n < 2 {
+			continue
+		}
+		for mask := 1; mask < 1<<n-1; mask++ {
+			var antecedent, consequent []string
+			for j, item := range itemset.Items {
+				if mask&(1<<j) != 0 {
+					antecedent = append(antecedent, item)
+				} else {
+					consequent = append(consequent, item)
+				}
+			}
+
+			antecedentCount := counts[strings.Join(antecedent, "\x00")]
+			consequentCount := counts[strings.Join(consequent, "\x00")]
+			if antecedentCount == 0 || consequentCount == 0 {
+				continue
+			}
+			confidence := float64(itemset.Count) / float64(antecedentCount)
+			lift := confidence / (float64(consequentCount) / float64(baskets))
+			if confidence < minConfidence || lift < minLift {
+				continue
+			}
+
+			rules = append(rules, models.AssociationRule{
+				Antecedent: antecedent,
+				Consequent: consequent,
+				Count:      itemset.Count,
+				Support:    float64(itemset.Count) / float64(baskets),
+				Confidence: confidence,
+				Lift:       lift,
+			})
+		}
+	}
+
+	sort.SliceStable(rules, func(a, b int) bool {
+		if rules[a].Lift != rules[b].Lift {
+			return rules[a].Lift > rules[b].Lift
+		}
+		if rules[a].Confidence != rules[b].Confidence {
+			return rules[a].Confidence > rules[b].Confidence
+		}
+		return rules[a].Count > rules[b].Count
+	})
+	return rules
+}
+
+func itemsetKey(itemset []int) string {
+	key := make([]byte, 0, len(itemset)*4)
+	for i, item := range itemset {
+		if i > 0 {
+			key = append(key, ',')
+		}
+		key = strconv.AppendInt(key, int64(item), 10)
+	}
+	return string(key)
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// containsSorted reports whether the sorted set contains every item of the
+// sorted subset.
+func containsSorted(set, subset []int) bool {
+	i := 0
+	for _, item := range subset {
+		for i < len(set) && set[i] < item {
+			i++
+		}
+		if i == len(set) || set[i] != item {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+// binomial returns n choose k, saturating at math.MaxInt.
+func binomial(n, k int) int {
+	result := 1
+	for i := 1; i <= k; i++ {
+		if result > math.MaxInt/(n-k+i) {
+			return math.MaxInt
+		}
+		result = result * (n - k + i) / i
+	}
+	return result
+}
+
+// forEachCombination calls fn with every size-k subset of items, in order.
+// The slice passed to fn is reused between calls.
+func forEachCombination(items []int, k int, fn func([]int)) {
+	subset := make([]int, k)
+	var walk func(start, depth int)
+	walk = func(start, depth int) {
+		if depth == k {
+			fn(subset)
+			return
+		}
+		for i := start; i <= len(items)-(k-depth); i++ {
+			subset[depth] = items[i]
+			walk(i+1, depth+1)
+		}
+	}
+	walk(0, 0)
+}
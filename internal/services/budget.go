@@ -0,0 +1,336 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var (
+	ErrInvalidBudgetParameter      = errors.New("invalid budget allocation parameter")
+	ErrInsufficientPerformanceData = errors.New("insufficient performance data")
+)
+
+const (
+	// minResponseCurveDays is the number of days with spend needed to fit a
+	// campaign's response curve
+	minResponseCurveDays = 5
+
+	// budgetAllocationSteps is the number of increments the budget above the
+	// campaign minimums is handed out in
+	budgetAllocationSteps = 1000
+)
+
+// budgetObjectives maps each objective to the performance field it sums
+var budgetObjectives = map[string]string{
+	"revenue":     "$revenue",
+	"conversions": "$conversions",
+}
+
+// ResponseCurveValue returns the outcome the curve projects for a daily spend.
+func ResponseCurveValue(curve models.ResponseCurve, spend float64) float64 {
+	if spend <= 0 {
+		return 0
+	}
+
+	p := curve.Parameters
+	switch curve.Type {
+	case "log":
+		return p["scale"] * math.Log1p(p["rate"]*spend)
+	default:
+		x := math.Pow(spend, p["shape"])
+		return p["max"] * x / (x + math.Pow(p["half_saturation"], p["shape"]))
+	}
+}
+
+// FitResponseCurve fits a concave response curve of outcome against spend by
+// least squares. A hill curve max * x^shape / (x^shape + half_saturation^shape)
+// keeps its shape at most 1 so returns only diminish; a log curve is
+// scale * ln(1 + rate * x). Spend must not be all zero.
+func FitResponseCurve(curveType string, spend, outcome []float64) models.ResponseCurve {
+	var meanSpend, meanOutcome, maxOutcome float64
+	for i := range spend {
+		meanSpend += spend[i]
+		meanOutcome += outcome[i]
+		maxOutcome = math.Max(maxOutcome, outcome[i])
+	}
+	meanSpend /= float64(len(spend))
+	meanOutcome /= float64(len(outcome))
+	meanOutcome = math.Max(meanOutcome, 1e-9)
+	maxOutcome = math.Max(maxOutcome, 1e-9)
+
+	// Parameters are optimized on an unconstrained scale
+	var curve func(x []float64) models.ResponseCurve
+	var x0 []float64
+	switch curveType {
+	case "log":
+		curve = func(x []float64) models.ResponseCurve {
+			return models.ResponseCurve{Type: "log", Parameters: map[string]float64{
+				"scale": math.Exp(x[0]),
+				"rate":  math.Exp(x[1]),
+			}}
+		}
+		x0 = []float64{math.Log(meanOutcome / math.Ln2), math.Log(1 / meanSpend)}
+	default:
+		curve = func(x []float64) models.ResponseCurve {
+			return models.ResponseCurve{Type: "hill", Parameters: map[string]float64{
+				"max":             math.Exp(x[0]),
+				"half_saturation": math.Exp(x[1]),
+				"shape":           1 / (1 + math.Exp(-x[2])),
+			}}
+		}
+		x0 = []float64{math.Log(2 * maxOutcome), math.Log(meanSpend), 2}
+	}
+
+	sse := func(c models.ResponseCurve) float64 {
+		var total float64
+		for i := range spend {
+			e := outcome[i] - ResponseCurveValue(c, spend[i])
+			total += e * e
+		}
+		return total
+	}
+	best := curve(nelderMead(func(x []float64) float64 { return sse(curve(x)) }, x0, 2000, 1e-10))
+
+	var sst float64
+	for _, y := range outcome {
+		sst += (y - meanOutcome) * (y - meanOutcome)
+	}
+	if sst > 0 {
+		best.RSquared = 1 - sse(best)/sst
+	}
+	return best
+}
+
+// AllocateBudget starts every campaign at its minimum spend and hands out the
+// rest of the total in small increments, each to the campaign with the highest
+// marginal return, never exceeding a campaign's maximum. For concave curves
+// this maximizes the total projected outcome. Budget left once every campaign
+// is at its maximum stays unallocated.
+func AllocateBudget(curves []models.ResponseCurve, minSpend, maxSpend []float64, total float64, steps int) []float64 {
+	spend := append([]float64(nil), minSpend...)
+	remaining := total
+	for _, s := range minSpend {
+		remaining -= s
+	}
+	if remaining <= 0 {
+		return spend
+	}
+
+	step := remaining / float64(steps)
+	for remaining > step*1e-6 {
+		increment := math.Min(step, remaining)
+		best, bestGain, bestIncrement := -1, 0.0, 0.0
+		for i, curve := range curves {
+			room := maxSpend[i] - spend[i]
+			if room <= 0 {
+				continue
+			}
+			inc := math.Min(increment, room)
+			gain := (ResponseCurveValue(curve, spend[i]+inc) - ResponseCurveValue(curve, spend[i])) / inc
+			if best < 0 || gain > bestGain {
+				best, bestGain, bestIncrement = i, gain, inc
+			}
+		}
+		if best < 0 {
+			break
+		}
+		spend[best] += bestIncrement
+		remaining -= bestIncrement
+	}
+	return spend
+}
+
+// campaignDailyPerformance is a campaign's spend and outcome per day
+type campaignDailyPerformance struct {
+	spend   []float64
+	outcome []float64
+}
+
+// AllocateBudgetAcrossCampaigns fits a response curve of the objective
+// against daily spend for each campaign from its recent performance, then
+// splits the daily budget to maximize the total projected outcome within each
+// campaign's spend bounds.
+func (s *AnalyticsService) AllocateBudgetAcrossCampaigns(ctx context.Context, req models.BudgetAllocationRequest) (*models.BudgetAllocation, error) {
+	objective := req.Objective
+	if objective == "" {
+		objective = "revenue"
+	}
+	outcomeField, ok := budgetObjectives[objective]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported objective %s", ErrInvalidBudgetParameter, objective)
+	}
+	curveType := req.Curve
+	if curveType == "" {
+		curveType = "hill"
+	}
+	if curveType != "hill" && curveType != "log" {
+		return nil, fmt.Errorf("%w: unsupported curve %s", ErrInvalidBudgetParameter, curveType)
+	}
+	if req.TotalBudget <= 0 {
+		return nil, fmt.Errorf("%w: total_budget must be positive", ErrInvalidBudgetParameter)
+	}
+	historyDays := req.HistoryDays
+	if historyDays == 0 {
+		historyDays = 90
+	}
+	if historyDays < 0 {
+		return nil, fmt.Errorf("%w: history_days must be positive", ErrInvalidBudgetParameter)
+	}
+
+	constraints := make(map[string]models.CampaignBudgetConstraint, len(req.Campaigns))
+	var minTotal float64
+	for _, constraint := range req.Campaigns {
+		if _, ok := constraints[constraint.CampaignID]; ok {
+			return nil, fmt.Errorf("%w: campaign %s is listed twice", ErrInvalidBudgetParameter, constraint.CampaignID)
+		}
+		if constraint.MinSpend < 0 || constraint.MaxSpend < 0 || (constraint.MaxSpend > 0 && constraint.MinSpend > constraint.MaxSpend) {
+			return nil, fmt.Errorf("%w: invalid spend bounds for campaign %s", ErrInvalidBudgetParameter, constraint.CampaignID)
+		}
+		constraints[constraint.CampaignID] = constraint
+		minTotal += constraint.MinSpend
+	}
+	if minTotal > req.TotalBudget {
+		return nil, fmt.Errorf("%w: campaign minimums exceed the total budget", ErrInvalidBudgetParameter)
+	}
+
+	match := bson.M{"date": bson.M{"$gte": time.Now().AddDate(0, 0, -historyDays)}}
+	if len(constraints) > 0 {
+		campaignIDs := make([]string, 0, len(constraints))
+		for campaignID := range constraints {
+			campaignIDs = append(campaignIDs, campaignID)
+		}
+		match["campaign_id"] = bson.M{"$in": campaignIDs}
+	}
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$group": bson.M{
+			"_id": bson.M{
+				"campaign_id": "$campaign_id",
+				"day":         bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$date"}},
+			},
+			"spend":   bson.M{"$sum": "$cost"},
+			"outcome": bson.M{"$sum": outcomeField},
+		}},
+	}
+	cursor, err := s.db.Collection("campaign_performance").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate campaign performance: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	performance := make(map[string]*campaignDailyPerformance)
+	for cursor.Next(ctx) {
+		var row struct {
+			ID struct {
+				CampaignID string `bson:"campaign_id"`
+			} `bson:"_id"`
+			Spend   float64 `bson:"spend"`
+			Outcome float64 `bson:"outcome"`
+		}
+		if err := cursor.Decode(&row); err != nil {
+			return nil, fmt.Errorf("failed to decode campaign performance: %w", err)
+		}
+		daily := performance[row.ID.CampaignID]
+		if daily == nil {
+			daily = &campaignDailyPerformance{}
+			performance[row.ID.CampaignID] = daily
+		}
+		daily.spend = append(daily.spend, row.Spend)
+		daily.outcome = append(daily.outcome, row.Outcome)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read campaign performance: %w", err)
+	}
+
+	campaignIDs := make([]string, 0, len(performance))
+	for campaignID := range performance {
+		campaignIDs = append(campaignIDs, campaignID)
+	}
+	for campaignID := range constraints {
+		if performance[campaignID] == nil {
+			return nil, fmt.Errorf("%w: no performance for campaign %s in the last %d days", ErrInsufficientPerformanceData, campaignID, historyDays)
+		}
+	}
+	if len(campaignIDs) == 0 {
+		return nil, fmt.Errorf("%w: no campaign performance in the last %d days", ErrInsufficientPerformanceData, historyDays)
+	}
+	sort.Strings(campaignIDs)
+
+	// A response curve needs enough days with spend. Listed campaigns must
+	// have them; others found in the history are skipped instead
+	var skipped []models.SkippedCampaign
+	fitted := campaignIDs[:0]
+	for _, campaignID := range campaignIDs {
+		var spendDays int
+		for _, spend := range performance[campaignID].spend {
+			if spend > 0 {
+				spendDays++
+			}
+		}
+		if spendDays >= minResponseCurveDays {
+			fitted = append(fitted, campaignID)
+			continue
+		}
+		if _, listed := constraints[campaignID]; listed {
+			return nil, fmt.Errorf("%w: campaign %s needs at least %d days with spend", ErrInsufficientPerformanceData, campaignID, minResponseCurveDays)
+		}
+		skipped = append(skipped, models.SkippedCampaign{CampaignID: campaignID, SpendDays: spendDays})
+	}
+	campaignIDs = fitted
+	if len(campaignIDs) == 0 {
+		return nil, fmt.Errorf("%w: no campaign has at least %d days with spend in the last %d days", ErrInsufficientPerformanceData, minResponseCurveDays, historyDays)
+	}
+
+	curves := make([]models.ResponseCurve, len(campaignIDs))
+	minSpend := make([]float64, len(campaignIDs))
+	maxSpend := make([]float64, len(campaignIDs))
+	currentSpend := make([]float64, len(campaignIDs))
+	for i, campaignID := range campaignIDs {
+		daily := performance[campaignID]
+		for _, spend := range daily.spend {
+			currentSpend[i] += spend
+		}
+		currentSpend[i] /= float64(len(daily.spend))
+
+		curves[i] = FitResponseCurve(curveType, daily.spend, daily.outcome)
+		minSpend[i] = constraints[campaignID].MinSpend
+		maxSpend[i] = math.Inf(1)
+		if constraints[campaignID].MaxSpend > 0 {
+			maxSpend[i] = constraints[campaignID].MaxSpend
+		}
+	}
+
+	spend := AllocateBudget(curves, minSpend, maxSpend, req.TotalBudget, budgetAllocationSteps)
+
+	allocation := &models.BudgetAllocation{
+		Objective:   objective,
+		TotalBudget: req.TotalBudget,
+		Campaigns:   make([]models.CampaignAllocation, len(campaignIDs)),
+		Skipped:     skipped,
+	}
+	for i, campaignID := range campaignIDs {
+		projected := ResponseCurveValue(curves[i], spend[i])
+		h := math.Max(spend[i]*1e-3, 1e-6)
+		allocation.Campaigns[i] = models.CampaignAllocation{
+			CampaignID:       campaignID,
+			CurrentSpend:     currentSpend[i],
+			RecommendedSpend: spend[i],
+			ProjectedOutcome: projected,
+			MarginalReturn:   (ResponseCurveValue(curves[i], spend[i]+h) - projected) / h,
+			Curve:            curves[i],
+		}
+		allocation.Allocated += spend[i]
+		allocation.ProjectedOutcome += projected
+		allocation.CurrentSpend += currentSpend[i]
+		allocation.CurrentOutcome += ResponseCurveValue(curves[i], currentSpend[i])
+	}
+
+	return allocation, nil
+}
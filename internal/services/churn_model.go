@@ -0,0 +1,295 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const churnModelType = "churn"
+
+var (
+	ErrInvalidTrainingWindow    = errors.New("invalid training window")
+	ErrInsufficientTrainingData = errors.New("insufficient training data")
+)
+
+// churnFeatureNames lists the inputs of newly trained churn models. Models
+// record their own feature names, so older models keep scoring with the
+// features they were trained on.
+var churnFeatureNames = []string{
+	"recency_days",
+	"frequency",
+	"monetary",
+	"avg_order_value",
+	"tenure_days",
+	"purchases_last_90_days",
+	"inter_purchase_mean_days",
+	"inter_purchase_std_days",
+	"category_diversity",
+	"online_ratio",
+	"spend_30d",
+	"spend_365d",
+}
+
+// purchaseSnapshot summarizes a customer's purchases before a cutoff date and
+// counts the purchases made in the window that follows it.
+type purchaseSnapshot struct {
+	CustomerID       string       `bson:"_id"`
+	Frequency        int          `bson:"frequency"`
+	Monetary         float64      `bson:"monetary"`
+	LastPurchaseDate *time.Time   `bson:"last_purchase_date"`
+	RecentPurchases  int          `bson:"recent_purchases"`
+	FuturePurchases  int          `bson:"future_purchases"`
+	NextPurchaseDate *time.Time   `bson:"next_purchase_date"` // First purchase on or after the cutoff
+	PurchaseDates    []*time.Time `bson:"purchase_dates"`     // Unordered, nil for purchases after the cutoff
+	Categories       []*string    `bson:"categories"`         // Distinct, may contain nil
+	OnlinePurchases  int          `bson:"online_purchases"`
+	Spend30d         float64      `bson:"spend_30d"`
+	Spend90d         float64      `bson:"spend_90d"`
+	Spend365d        float64      `bson:"spend_365d"`
+}
+
+// purchaseSnapshots summarizes purchases matching filter as of cutoff.
+// Purchases between cutoff and windowEnd are only counted in FuturePurchases
+// and NextPurchaseDate.
+func (s *AnalyticsService) purchaseSnapshots(ctx context.Context, filter bson.M, cutoff, windowEnd time.Time) (map[string]purchaseSnapshot, error) {
+	match := bson.M{"purchase_date": bson.M{"$lt": windowEnd}}
+	for key, value := range filter {
+		match[key] = value
+	}
+
+	beforeCutoff := bson.M{"$lt": bson.A{"$purchase_date", cutoff}}
+	withinDays := func(days int) bson.M {
+		return bson.M{"$and": bson.A{
+			beforeCutoff,
+			bson.M{"$gte": bson.A{"$purchase_date", cutoff.AddDate(0, 0, -days)}},
+		}}
+	}
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$group": bson.M{
+			"_id":                "$customer_id",
+			"frequency":          bson.M{"$sum": bson.M{"$cond": bson.A{beforeCutoff, 1, 0}}},
+			"monetary":           bson.M{"$sum": bson.M{"$cond": bson.A{beforeCutoff, "$amount", 0}}},
+			"last_purchase_date": bson.M{"$max": bson.M{"$cond": bson.A{beforeCutoff, "$purchase_date", nil}}},
+			"recent_purchases":   bson.M{"$sum": bson.M{"$cond": bson.A{withinDays(90), 1, 0}}},
+			"future_purchases":   bson.M{"$sum": bson.M{"$cond": bson.A{beforeCutoff, 0, 1}}},
+			"next_purchase_date": bson.M{"$min": bson.M{"$cond": bson.A{beforeCutoff, nil, "$purchase_date"}}},
+			"purchase_dates":     bson.M{"$push": bson.M{"$cond": bson.A{beforeCutoff, "$purchase_date", nil}}},
+			"categories":         bson.M{"$addToSet": bson.M{"$cond": bson.A{beforeCutoff, "$category", nil}}},
+			"online_purchases": bson.M{"$sum": bson.M{"$cond": bson.A{
+				bson.M{"$and": bson.A{beforeCutoff, bson.M{"$eq": bson.A{"$channel", "online"}}}},
+				1, 0,
+			}}},
+			"spend_30d":  bson.M{"$sum": bson.M{"$cond": bson.A{withinDays(30), "$amount", 0}}},
+			"spend_90d":  bson.M{"$sum": bson.M{"$cond": bson.A{withinDays(90), "$amount", 0}}},
+			"spend_365d": bson.M{"$sum": bson.M{"$cond": bson.A{withinDays(365), "$amount", 0}}},
+		}},
+	}
+
+	cursor, err := s.db.Collection("purchases").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate purchases: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	snapshots := make(map[string]purchaseSnapshot)
+	for cursor.Next(ctx) {
+		var snapshot purchaseSnapshot
+		if err := cursor.Decode(&snapshot); err != nil {
+			return nil, fmt.Errorf("failed to decode purchase snapshot: %w", err)
+		}
+		snapshots[snapshot.CustomerID] = snapshot
+	}
+
+	return snapshots, cursor.Err()
+}
+
+// churnFeatures builds the named churn model inputs for a customer as of a
+// date. Names the current code does not know are scored as zero.
+func churnFeatures(names []string, customer models.Customer, snapshot purchaseSnapshot, asOf time.Time) []float64 {
+	derived := deriveCustomerFeatures(customer, snapshot, asOf)
+	tenureDays := math.Max(0, derived.DaysSinceRegistration)
+
+	recencyDays := tenureDays
+	if snapshot.LastPurchaseDate != nil {
+		recencyDays = asOf.Sub(*snapshot.LastPurchaseDate).Hours() / 24
+	}
+
+	values := map[string]float64{
+		"recency_days":             recencyDays,
+		"frequency":                float64(snapshot.Frequency),
+		"monetary":                 snapshot.Monetary,
+		"avg_order_value":          derived.AvgOrderValue,
+		"tenure_days":              tenureDays,
+		"purchases_last_90_days":   float64(snapshot.RecentPurchases),
+		"inter_purchase_mean_days": derived.InterPurchaseMeanDays,
+		"inter_purchase_std_days":  derived.InterPurchaseStdDays,
+		"category_diversity":       float64(derived.CategoryDiversity),
+		"online_ratio":             derived.OnlineRatio,
+		"spend_30d":                derived.Spend30d,
+		"spend_365d":               derived.Spend365d,
+	}
+
+	features := make([]float64, len(names))
+	for i, name := range names {
+		features[i] = values[name]
+	}
+	return features
+}
+
+// TrainChurnModel fits a logistic regression that predicts whether a customer
+// makes no purchase within the window following the cutoff date, using only
+// purchases made before the cutoff as features.
+func (s *AnalyticsService) TrainChurnModel(ctx context.Context, req models.ChurnTrainingRequest) (*models.TrainedModel, error) {
+	now := time.Now()
+
+	windowDays := req.WindowDays
+	if windowDays == 0 {
+		windowDays = 90
+	}
+	if windowDays < 0 {
+		return nil, fmt.Errorf("%w: window_days must be positive", ErrInvalidTrainingWindow)
+	}
+
+	cutoff := now.AddDate(0, 0, -windowDays)
+	if req.CutoffDate != nil {
+		cutoff = *req.CutoffDate
+	}
+	windowEnd := cutoff.AddDate(0, 0, windowDays)
+	if windowEnd.After(now) {
+		return nil, fmt.Errorf("%w: the window after the cutoff date must end in the past", ErrInvalidTrainingWindow)
+	}
+
+	snapshots, err := s.purchaseSnapshots(ctx, bson.M{}, cutoff, windowEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only customers who had purchased before the cutoff can churn
+	var features [][]float64
+	var labels []float64
+	err = s.streamCustomers(ctx, func(customer models.Customer) error {
+		snapshot, ok := snapshots[customer.CustomerID]
+		if !ok || snapshot.Frequency == 0 || !customer.RegistrationDate.Before(cutoff) {
+			return nil
+		}
+
+		features = append(features, churnFeatures(churnFeatureNames, customer, snapshot, cutoff))
+		if snapshot.FuturePurchases == 0 {
+			labels = append(labels, 1)
+		} else {
+			labels = append(labels, 0)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var churned float64
+	for _, label := range labels {
+		churned += label
+	}
+	if len(labels) < 10 || churned == 0 || churned == float64(len(labels)) {
+		return nil, fmt.Errorf("%w: need at least 10 customers with both churned and retained examples, got %d customers (%d churned)",
+			ErrInsufficientTrainingData, len(labels), int(churned))
+	}
+
+	params := req.Parameters
+	rng := rand.New(rand.NewSource(int64(paramFloat(params, "seed", float64(now.UnixNano())))))
+	order := rng.Perm(len(labels))
+
+	// Hold out a share of customers to report honest metrics
+	holdout := int(float64(len(labels)) * paramFloat(params, "holdout_fraction", 0.2))
+	if holdout < 2 || len(labels)-holdout < 2 {
+		holdout = 0
+	}
+	var trainX, testX [][]float64
+	var trainY, testY []float64
+	for i, idx := range order {
+		if i < holdout {
+			testX, testY = append(testX, features[idx]), append(testY, labels[idx])
+		} else {
+			trainX, trainY = append(trainX, features[idx]), append(trainY, labels[idx])
+		}
+	}
+	if holdout == 0 {
+		testX, testY = trainX, trainY
+	}
+
+	logistic, err := TrainLogisticRegression(trainX, trainY, churnFeatureNames, LogisticConfig{
+		LearningRate: paramFloat(params, "learning_rate", 0.1),
+		Iterations:   paramInt(params, "iterations", 1000),
+		L2:           paramFloat(params, "l2", 0.01),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to train churn model: %w", err)
+	}
+
+	probabilities := make([]float64, len(testX))
+	var correct float64
+	for i, x := range testX {
+		probabilities[i] = PredictLogistic(logistic, x)
+		if (probabilities[i] >= 0.5) == (testY[i] == 1) {
+			correct++
+		}
+	}
+
+	window, err := s.trainingWindow(ctx, windowEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	model := models.TrainedModel{
+		ID:             primitive.NewObjectID(),
+		ModelType:      churnModelType,
+		TrainingWindow: window,
+		Parameters: map[string]interface{}{
+			"cutoff_date":   cutoff,
+			"window_days":   windowDays,
+			"learning_rate": paramFloat(params, "learning_rate", 0.1),
+			"iterations":    paramInt(params, "iterations", 1000),
+			"l2":            paramFloat(params, "l2", 0.01),
+		},
+		Logistic: logistic,
+		Metrics: map[string]float64{
+			"auc":        AUC(probabilities, testY),
+			"log_loss":   LogLoss(probabilities, testY),
+			"accuracy":   correct / float64(len(testY)),
+			"churn_rate": churned / float64(len(labels)),
+			"holdout":    float64(holdout),
+		},
+		TrainingSamples: len(trainY),
+		TrainedAt:       now,
+	}
+
+	if err := s.registerModel(ctx, &model); err != nil {
+		return nil, err
+	}
+
+	return &model, nil
+}
+
+// predictChurnWithModel scores a customer with a trained churn model from
+// their purchase snapshot as of now. The confidence is the probability the
+// model assigns to its predicted class.
+func predictChurnWithModel(customer models.Customer, model *models.TrainedModel, snapshot purchaseSnapshot, now time.Time) models.PredictionResult {
+	probability := PredictLogistic(model.Logistic, churnFeatures(model.Logistic.FeatureNames, customer, snapshot, now))
+
+	return models.PredictionResult{
+		CustomerID:     customer.CustomerID,
+		PredictionType: "churn",
+		Probability:    probability,
+		Confidence:     math.Max(probability, 1-probability),
+		ModelVersion:   model.Version,
+		CreatedAt:      now,
+	}
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"math"
+	"math/rand"
+)
+
+// WithinClusterSumOfSquares returns the total squared distance of every
+// clustered point to its cluster centroid.
+func WithinClusterSumOfSquares(points [][]float64, labels []int) float64 {
+	centroids := clusterCentroids(points, labels)
+
+	var wcss float64
+	for i, label := range labels {
+		if label == NoiseLabel {
+			continue
+		}
+		wcss += squaredDistance(points[i], centroids[label])
+	}
+	return wcss
+}
+
+// SilhouetteScore returns the mean silhouette coefficient over all clustered
+// points, in [-1, 1]. Points in singleton clusters score 0. Fewer than two
+// clusters yields 0.
+func SilhouetteScore(points [][]float64, labels []int) float64 {
+	clusters := 0
+	for _, label := range labels {
+		clusters = max(clusters, label+1)
+	}
+	if clusters < 2 {
+		return 0
+	}
+
+	sizes := make([]int, clusters)
+	for _, label := range labels {
+		if label != NoiseLabel {
+			sizes[label]++
+		}
+	}
+
+	var total float64
+	var counted int
+	distanceSums := make([]float64, clusters)
+	for i, label := range labels {
+		if label == NoiseLabel {
+			continue
+		}
+		counted++
+		if sizes[label] <= 1 {
+			continue
+		}
+
+		for c := range distanceSums {
+			distanceSums[c] = 0
+		}
+		for j, other := range labels {
+			if i == j || other == NoiseLabel {
+				continue
+			}
+			distanceSums[other] += euclideanDistance(points[i], points[j])
+		}
+
+		a := distanceSums[label] / float64(sizes[label]-1)
+		b := math.Inf(1)
+		for c, sum := range distanceSums {
+			if c != label && sizes[c] > 0 {
+				b = math.Min(b, sum/float64(sizes[c]))
+			}
+		}
+		if math.IsInf(b, 1) {
+			continue
+		}
+		if denom := math.Max(a, b); denom > 0 {
+			total += (b - a) / denom
+		}
+	}
+
+	if counted == 0 {
+		return 0
+	}
+	return total / float64(counted)
+}
+
+// DaviesBouldinIndex returns the average, over clusters, of the worst ratio of
+// within-cluster scatter to between-centroid separation. Lower is better.
+// Fewer than two clusters yields 0.
+func DaviesBouldinIndex(points [][]float64, labels []int) float64 {
+	centroids := clusterCentroids(points, labels)
+	if len(centroids) < 2 {
+		return 0
+	}
+
+	scatter := make([]float64, len(centroids))
+	sizes := make([]int, len(centroids))
+	for i, label := range labels {
+		if label == NoiseLabel {
+			continue
+		}
+		scatter[label] += euclideanDistance(points[i], centroids[label])
+		sizes[label]++
+	}
+
+	var clusters int
+	for c := range scatter {
+		if sizes[c] > 0 {
+			scatter[c] /= float64(sizes[c])
+			clusters++
+		}
+	}
+	if clusters < 2 {
+		return 0
+	}
+
+	var total float64
+	for i := range centroids {
+		if sizes[i] == 0 {
+			continue
+		}
+		worst := 0.0
+		for j := range centroids {
+			if i == j || sizes[j] == 0 {
+				continue
+			}
+			separation := euclideanDistance(centroids[i], centroids[j])
+			if separation == 0 {
+				continue
+			}
+			worst = math.Max(worst, (scatter[i]+scatter[j])/separation)
+		}
+		total += worst
+	}
+
+	return total / float64(clusters)
+}
+
+// samplePoints returns at most size points chosen uniformly at random along
+// with their labels. The inputs are returned unchanged when already small
+// enough.
+func samplePoints(points [][]float64, labels []int, size int, rng *rand.Rand) ([][]float64, []int) {
+	if size <= 0 || len(points) <= size {
+		return points, labels
+	}
+
+	sampledPoints := make([][]float64, size)
+	sampledLabels := make([]int, size)
+	for i, idx := range rng.Perm(len(points))[:size] {
+		sampledPoints[i] = points[idx]
+		if labels != nil {
+			sampledLabels[i] = labels[idx]
+		}
+	}
+	return sampledPoints, sampledLabels
+}
+
+// elbowCurve runs k-means for every k in [minK, maxK] and records the WCSS and
+// silhouette score of each.
+func elbowCurve(points [][]float64, minK, maxK int, seed int64) []models.ElbowPoint {
+	minK = max(minK, 2)
+	maxK = min(maxK, len(points)-1)
+
+	var curve []models.ElbowPoint
+	for k := minK; k <= maxK; k++ {
+		result, err := KMeans(points, KMeansConfig{K: k, MaxIterations: 100, Tolerance: 1e-4, Seed: seed})
+		if err != nil {
+			continue
+		}
+		curve = append(curve, models.ElbowPoint{
+			K:          k,
+			WCSS:       WithinClusterSumOfSquares(points, result.Labels),
+			Silhouette: SilhouetteScore(points, result.Labels),
+		})
+	}
+	return curve
+}
+
+// bestSilhouetteK returns the k with the highest silhouette on the curve.
+func bestSilhouetteK(curve []models.ElbowPoint) (int, bool) {
+	if len(curve) == 0 {
+		return 0, false
+	}
+	best := curve[0]
+	for _, point := range curve[1:] {
+		if point.Silhouette > best.Silhouette {
+			best = point
+		}
+	}
+	return best.K, true
+}
@@ -0,0 +1,424 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnsupportedFeature   = errors.New("unsupported segmentation feature")
+	ErrUnsupportedAlgorithm = errors.New("unsupported segmentation algorithm")
+	ErrInvalidParameter     = errors.New("invalid segmentation parameter")
+)
+
+// ClusteringAlgorithm groups standardized feature vectors into clusters.
+type ClusteringAlgorithm interface {
+	Cluster(points [][]float64, params map[string]interface{}) (*ClusteringResult, error)
+}
+
+// ClusteringResult holds the cluster label of every point. A label of -1
+// marks a noise point that belongs to no cluster.
+type ClusteringResult struct {
+	Labels  []int
+	Details map[string]interface{}
+}
+
+// NoiseLabel is the label assigned to points that belong to no cluster.
+const NoiseLabel = -1
+
+// maxPairwiseClusteringPoints bounds the customers DBSCAN and hierarchical
+// clustering accept, since both compare every pair of points
+const maxPairwiseClusteringPoints = 5000
+
+var clusteringAlgorithms = map[string]ClusteringAlgorithm{
+	"kmeans":       kMeansAlgorithm{},
+	"dbscan":       dbscanAlgorithm{},
+	"hierarchical": hierarchicalAlgorithm{},
+}
+
+// RegisterClusteringAlgorithm makes a clustering algorithm available to
+// segmentation requests under the given name.
+func RegisterClusteringAlgorithm(name string, algorithm ClusteringAlgorithm) {
+	clusteringAlgorithms[strings.ToLower(name)] = algorithm
+}
+
+// GetClusteringAlgorithm looks up a registered clustering algorithm by name.
+func GetClusteringAlgorithm(name string) (ClusteringAlgorithm, error) {
+	algorithm, ok := clusteringAlgorithms[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, name)
+	}
+	return algorithm, nil
+}
+
+// customerFeatureExtractors maps the numeric Customer fields (by JSON name)
+// that can be used as segmentation dimensions to their extractors.
+var customerFeatureExtractors = map[string]func(models.Customer, time.Time) float64{
+	"age": func(c models.Customer, _ time.Time) float64 {
+		return float64(c.Age)
+	},
+	"total_spent": func(c models.Customer, _ time.Time) float64 {
+		return c.TotalSpent
+	},
+	"purchase_frequency": func(c models.Customer, _ time.Time) float64 {
+		return float64(c.PurchaseFrequency)
+	},
+	"days_since_registration": func(c models.Customer, now time.Time) float64 {
+		return now.Sub(c.RegistrationDate).Hours() / 24
+	},
+	"days_since_last_purchase": func(c models.Customer, now time.Time) float64 {
+		if c.LastPurchaseDate == nil {
+			return now.Sub(c.RegistrationDate).Hours() / 24
+		}
+		return now.Sub(*c.LastPurchaseDate).Hours() / 24
+	},
+}
+
+// customerVectorizer builds the feature vector of a customer for the
+// requested segmentation features
+type customerVectorizer struct {
+	vectorize          func(customerRecord, time.Time) []float64
+	usesStoredFeatures bool // Whether any feature comes from the feature store
+}
+
+// customerFeatureVectorizer returns a vectorizer for the requested features,
+// which may mix Customer fields and feature store fields.
+func customerFeatureVectorizer(features []string) (*customerVectorizer, error) {
+	if len(features) == 0 {
+		return nil, fmt.Errorf("%w: at least one feature is required", ErrUnsupportedFeature)
+	}
+
+	vectorizer := &customerVectorizer{}
+	extractors := make([]func(customerRecord, time.Time) float64, len(features))
+	for i, feature := range features {
+		if extractor, ok := customerFeatureExtractors[feature]; ok {
+			extractors[i] = func(r customerRecord, now time.Time) float64 {
+				return extractor(r.Customer, now)
+			}
+			continue
+		}
+		if extractor, ok := storedFeatureExtractors[feature]; ok {
+			// Customers whose features were never computed count as zero
+			extractors[i] = func(r customerRecord, _ time.Time) float64 {
+				if len(r.StoredFeatures) == 0 {
+					return 0
+				}
+				return extractor(r.StoredFeatures[0])
+			}
+			vectorizer.usesStoredFeatures = true
+			continue
+		}
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFeature, feature)
+	}
+
+	vectorizer.vectorize = func(customer customerRecord, now time.Time) []float64 {
+		point := make([]float64, len(extractors))
+		for j, extractor := range extractors {
+			point[j] = extractor(customer, now)
+		}
+		return point
+	}
+	return vectorizer, nil
+}
+
+// standardize returns z-score scaled copies of the points together with the
+// per-dimension mean and standard deviation used for scaling.
+func standardize(points [][]float64) (scaled [][]float64, means, stds []float64) {
+	if len(points) == 0 {
+		return nil, nil, nil
+	}
+
+	stats := newRunningStats(len(points[0]))
+	for _, p := range points {
+		stats.add(p)
+	}
+	means, stds = stats.result()
+
+	scaled = make([][]float64, len(points))
+	for i, p := range points {
+		scaled[i] = standardizePoint(p, means, stds)
+	}
+
+	return scaled, means, stds
+}
+
+func standardizePoint(point, means, stds []float64) []float64 {
+	scaled := make([]float64, len(point))
+	for j, v := range point {
+		if stds[j] > 0 {
+			scaled[j] = (v - means[j]) / stds[j]
+		}
+	}
+	return scaled
+}
+
+// runningStats accumulates per-dimension mean and variance in a single pass
+// using Welford's algorithm, so statistics can be gathered while streaming.
+type runningStats struct {
+	count int
+	means []float64
+	m2    []float64
+}
+
+func newRunningStats(dims int) *runningStats {
+	return &runningStats{
+		means: make([]float64, dims),
+		m2:    make([]float64, dims),
+	}
+}
+
+func (r *runningStats) add(point []float64) {
+	r.count++
+	for j, v := range point {
+		delta := v - r.means[j]
+		r.means[j] += delta / float64(r.count)
+		r.m2[j] += delta * (v - r.means[j])
+	}
+}
+
+// result returns the means and population standard deviations seen so far.
+func (r *runningStats) result() (means, stds []float64) {
+	stds = make([]float64, len(r.m2))
+	if r.count > 0 {
+		for j, m2 := range r.m2 {
+			stds[j] = math.Sqrt(m2 / float64(r.count))
+		}
+	}
+	return append([]float64(nil), r.means...), stds
+}
+
+// clusterCentroids returns the mean point of every cluster in labels, indexed
+// by label. Noise points are ignored.
+func clusterCentroids(points [][]float64, labels []int) [][]float64 {
+	clusters := 0
+	for _, label := range labels {
+		if label+1 > clusters {
+			clusters = label + 1
+		}
+	}
+	if clusters == 0 || len(points) == 0 {
+		return nil
+	}
+
+	dims := len(points[0])
+	centroids := make([][]float64, clusters)
+	counts := make([]int, clusters)
+	for c := range centroids {
+		centroids[c] = make([]float64, dims)
+	}
+	for i, label := range labels {
+		if label == NoiseLabel {
+			continue
+		}
+		counts[label]++
+		for j, v := range points[i] {
+			centroids[label][j] += v
+		}
+	}
+	for c := range centroids {
+		if counts[c] == 0 {
+			continue
+		}
+		for j := range centroids[c] {
+			centroids[c][j] /= float64(counts[c])
+		}
+	}
+
+	return centroids
+}
+
+func euclideanDistance(a, b []float64) float64 {
+	return math.Sqrt(squaredDistance(a, b))
+}
+
+func squaredDistance(a, b []float64) float64 {
+	var sum float64
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return sum
+}
+
+// KMeansConfig holds the tuning parameters for KMeans.
+type KMeansConfig struct {
+	K             int
+	MaxIterations int
+	Tolerance     float64
+	Seed          int64
+}
+
+// KMeansResult holds the cluster assignment of every point and the final
+// centroids.
+type KMeansResult struct {
+	Labels     []int
+	Centroids  [][]float64
+	Iterations int
+}
+
+// KMeans clusters points into cfg.K groups using Lloyd's algorithm with
+// k-means++ initialisation. Iteration stops once no centroid moves more than
+// cfg.Tolerance or cfg.MaxIterations is reached.
+func KMeans(points [][]float64, cfg KMeansConfig) (*KMeansResult, error) {
+	if cfg.K <= 0 {
+		return nil, fmt.Errorf("%w: k must be greater than zero", ErrInvalidParameter)
+	}
+	if len(points) < cfg.K {
+		return nil, fmt.Errorf("%w: not enough data points (%d) for %d clusters", ErrInvalidParameter, len(points), cfg.K)
+	}
+	if cfg.MaxIterations <= 0 {
+		cfg.MaxIterations = 100
+	}
+
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	centroids := kMeansPlusPlusInit(points, cfg.K, rng)
+	labels := make([]int, len(points))
+	dims := len(points[0])
+
+	iterations := 0
+	for iterations < cfg.MaxIterations {
+		iterations++
+
+		// Assignment step
+		for i, p := range points {
+			labels[i] = nearestCentroid(p, centroids)
+		}
+
+		// Update step
+		sums := make([][]float64, cfg.K)
+		counts := make([]int, cfg.K)
+		for c := range sums {
+			sums[c] = make([]float64, dims)
+		}
+		for i, p := range points {
+			counts[labels[i]]++
+			for j, v := range p {
+				sums[labels[i]][j] += v
+			}
+		}
+
+		maxShift := 0.0
+		for c := range centroids {
+			if counts[c] == 0 {
+				// Re-seed an empty cluster with a random point
+				sums[c] = append([]float64(nil), points[rng.Intn(len(points))]...)
+				counts[c] = 1
+			}
+			next := make([]float64, dims)
+			for j := range next {
+				next[j] = sums[c][j] / float64(counts[c])
+			}
+			maxShift = math.Max(maxShift, math.Sqrt(squaredDistance(next, centroids[c])))
+			centroids[c] = next
+		}
+
+		if maxShift <= cfg.Tolerance {
+			break
+		}
+	}
+
+	for i, p := range points {
+		labels[i] = nearestCentroid(p, centroids)
+	}
+
+	return &KMeansResult{
+		Labels:     labels,
+		Centroids:  centroids,
+		Iterations: iterations,
+	}, nil
+}
+
+type kMeansAlgorithm struct{}
+
+func (kMeansAlgorithm) Cluster(points [][]float64, params map[string]interface{}) (*ClusteringResult, error) {
+	result, err := KMeans(points, KMeansConfig{
+		K:             paramInt(params, "k", 3),
+		MaxIterations: paramInt(params, "max_iterations", 100),
+		Tolerance:     paramFloat(params, "tolerance", 1e-4),
+		Seed:          int64(paramFloat(params, "seed", float64(time.Now().UnixNano()))),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ClusteringResult{
+		Labels:  result.Labels,
+		Details: map[string]interface{}{"iterations": result.Iterations},
+	}, nil
+}
+
+// kMeansPlusPlusInit picks k initial centroids, each subsequent one chosen
+// with probability proportional to its squared distance from the nearest
+// centroid already picked.
+func kMeansPlusPlusInit(points [][]float64, k int, rng *rand.Rand) [][]float64 {
+	centroids := make([][]float64, 0, k)
+	centroids = append(centroids, append([]float64(nil), points[rng.Intn(len(points))]...))
+
+	distances := make([]float64, len(points))
+	for len(centroids) < k {
+		var total float64
+		for i, p := range points {
+			distances[i] = squaredDistance(p, centroids[nearestCentroid(p, centroids)])
+			total += distances[i]
+		}
+
+		next := rng.Intn(len(points))
+		if total > 0 {
+			target := rng.Float64() * total
+			for i, d := range distances {
+				target -= d
+				if target <= 0 {
+					next = i
+					break
+				}
+			}
+		}
+		centroids = append(centroids, append([]float64(nil), points[next]...))
+	}
+
+	return centroids
+}
+
+func nearestCentroid(point []float64, centroids [][]float64) int {
+	best := 0
+	bestDistance := math.Inf(1)
+	for c, centroid := range centroids {
+		if d := squaredDistance(point, centroid); d < bestDistance {
+			best = c
+			bestDistance = d
+		}
+	}
+	return best
+}
+
+// Parameter helpers for the free-form Parameters maps on requests. JSON
+// numbers decode as float64; missing or mistyped values fall back to the
+// default.
+
+func paramFloat(params map[string]interface{}, key string, defaultValue float64) float64 {
+	switch v := params[key].(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	}
+	return defaultValue
+}
+
+func paramInt(params map[string]interface{}, key string, defaultValue int) int {
+	return int(paramFloat(params, key, float64(defaultValue)))
+}
+
+func paramBool(params map[string]interface{}, key string, defaultValue bool) bool {
+	if v, ok := params[key].(bool); ok {
+		return v
+	}
+	return defaultValue
+}
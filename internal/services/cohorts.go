@@ -0,0 +1,205 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrInvalidCohortParameter = errors.New("invalid cohort parameter")
+
+const maxCohortMonths = 60
+
+// CohortCell is the activity of a cohort in one month since it started.
+// Months are counted as year*12 + month-1.
+type CohortCell struct {
+	Cohort    int     `bson:"cohort"`
+	Offset    int     `bson:"offset"`
+	Customers int     `bson:"customers"`
+	Revenue   float64 `bson:"revenue"`
+}
+
+// monthIndex returns the aggregation expression counting the months of a date
+// as year*12 + month-1
+func monthIndex(date interface{}) bson.M {
+	return bson.M{"$add": bson.A{
+		bson.M{"$multiply": bson.A{bson.M{"$year": date}, 12}},
+		bson.M{"$subtract": bson.A{bson.M{"$month": date}, 1}},
+	}}
+}
+
+func monthLabel(index int) string {
+	return fmt.Sprintf("%04d-%02d", index/12, index%12+1)
+}
+
+// GetCohortRetention groups the customers who started in each of the last
+// months by registration or first purchase month, and measures the share of
+// each cohort that purchased, and the revenue it brought, in every month
+// since.
+func (s *AnalyticsService) GetCohortRetention(ctx context.Context, cohortBy string, months int) (*models.CohortAnalysis, error) {
+	if cohortBy == "" {
+		cohortBy = "registration"
+	}
+	if cohortBy != "registration" && cohortBy != "first_purchase" {
+		return nil, fmt.Errorf("%w: unsupported cohort_by %s", ErrInvalidCohortParameter, cohortBy)
+	}
+	if months == 0 {
+		months = 12
+	}
+	if months < 0 || months > maxCohortMonths {
+		return nil, fmt.Errorf("%w: months must be between 1 and %d", ErrInvalidCohortParameter, maxCohortMonths)
+	}
+
+	now := time.Now().UTC()
+	currentMonth := now.Year()*12 + int(now.Month()) - 1
+	firstMonth := currentMonth - months + 1
+
+	// Reduce purchases to one document per customer listing their monthly
+	// revenue, then assign each customer to a cohort
+	pipeline := []bson.M{
+		{"$group": bson.M{
+			"_id":     bson.M{"customer_id": "$customer_id", "month": monthIndex("$purchase_date")},
+			"revenue": bson.M{"$sum": "$amount"},
+		}},
+		{"$group": bson.M{
+			"_id":         "$_id.customer_id",
+			"first_month": bson.M{"$min": "$_id.month"},
+			"months":      bson.M{"$push": bson.M{"month": "$_id.month", "revenue": "$revenue"}},
+		}},
+	}
+	if cohortBy == "registration" {
+		pipeline = append(pipeline,
+			bson.M{"$lookup": bson.M{
+				"from":         "customers",
+				"localField":   "_id",
+				"foreignField": "customer_id",
+				"as":           "customer",
+			}},
+			bson.M{"$unwind": "$customer"},
+			bson.M{"$addFields": bson.M{"cohort": monthIndex("$customer.registration_date")}},
+		)
+	} else {
+		pipeline = append(pipeline, bson.M{"$addFields": bson.M{"cohort": "$first_month"}})
+	}
+	pipeline = append(pipeline,
+		bson.M{"$match": bson.M{"cohort": bson.M{"$gte": firstMonth}}},
+		bson.M{"$unwind": "$months"},
+		bson.M{"$project": bson.M{
+			"cohort":  1,
+			"offset":  bson.M{"$subtract": bson.A{"$months.month", "$cohort"}},
+			"revenue": "$months.revenue",
+		}},
+		// Purchases dated before registration belong to no cohort month
+		bson.M{"$match": bson.M{"offset": bson.M{"$gte": 0}}},
+		bson.M{"$group": bson.M{
+			"_id":       bson.M{"cohort": "$cohort", "offset": "$offset"},
+			"customers": bson.M{"$sum": 1},
+			"revenue":   bson.M{"$sum": "$revenue"},
+		}},
+		bson.M{"$project": bson.M{
+			"_id":       0,
+			"cohort":    "$_id.cohort",
+			"offset":    "$_id.offset",
+			"customers": 1,
+			"revenue":   1,
+		}},
+	)
+
+	cursor, err := s.db.Collection("purchases").Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate cohort activity: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var cells []CohortCell
+	if err := cursor.All(ctx, &cells); err != nil {
+		return nil, fmt.Errorf("failed to decode cohort activity: %w", err)
+	}
+
+	// Registration cohorts include the customers who never purchased;
+	// first purchase cohorts are exactly the customers active in month zero
+	sizes := make(map[int]int)
+	if cohortBy == "registration" {
+		if sizes, err = s.registrationCohortSizes(ctx, firstMonth); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, cell := range cells {
+			if cell.Offset == 0 {
+				sizes[cell.Cohort] = cell.Customers
+			}
+		}
+	}
+
+	return &models.CohortAnalysis{
+		CohortBy: cohortBy,
+		Cohorts:  CohortRows(sizes, cells, firstMonth, currentMonth),
+	}, nil
+}
+
+func (s *AnalyticsService) registrationCohortSizes(ctx context.Context, firstMonth int) (map[int]int, error) {
+	pipeline := []bson.M{
+		{"$group": bson.M{
+			"_id":       monthIndex("$registration_date"),
+			"customers": bson.M{"$sum": 1},
+		}},
+		{"$match": bson.M{"_id": bson.M{"$gte": firstMonth}}},
+	}
+	cursor, err := s.db.Collection("customers").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate cohort sizes: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	sizes := make(map[int]int)
+	for cursor.Next(ctx) {
+		var row struct {
+			Month     int `bson:"_id"`
+			Customers int `bson:"customers"`
+		}
+		if err := cursor.Decode(&row); err != nil {
+			return nil, fmt.Errorf("failed to decode cohort size: %w", err)
+		}
+		sizes[row.Month] = row.Customers
+	}
+	return sizes, cursor.Err()
+}
+
+// CohortRows lays the cells out as a matrix with one row per cohort month from
+// firstMonth to currentMonth, each running from the cohort's month to the
+// current one. Cohorts without customers are omitted.
+func CohortRows(sizes map[int]int, cells []CohortCell, firstMonth, currentMonth int) []models.CohortRow {
+	rows := []models.CohortRow{}
+	index := make(map[int]int)
+	for cohort := firstMonth; cohort <= currentMonth; cohort++ {
+		if sizes[cohort] == 0 {
+			continue
+		}
+		periods := currentMonth - cohort + 1
+		index[cohort] = len(rows)
+		rows = append(rows, models.CohortRow{
+			Cohort:    monthLabel(cohort),
+			Size:      sizes[cohort],
+			Active:    make([]int, periods),
+			Retention: make([]float64, periods),
+			Revenue:   make([]float64, periods),
+		})
+	}
+
+	for _, cell := range cells {
+		i, ok := index[cell.Cohort]
+		if !ok || cell.Offset < 0 || cell.Offset >= len(rows[i].Active) {
+			continue
+		}
+		row := &rows[i]
+		row.Active[cell.Offset] = cell.Customers
+		row.Retention[cell.Offset] = float64(cell.Customers) / float64(row.Size)
+		row.Revenue[cell.Offset] = cell.Revenue
+	}
+	return rows
+}
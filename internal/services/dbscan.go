@@ -0,0 +1,101 @@
+package services
+
+import "fmt"
+
+type dbscanAlgorithm struct{}
+
+func (dbscanAlgorithm) Cluster(points [][]float64, params map[string]interface{}) (*ClusteringResult, error) {
+	eps := paramFloat(params, "eps", 0.5)
+	minPts := paramInt(params, "min_pts", 5)
+
+	if len(points) > maxPairwiseClusteringPoints {
+		return nil, fmt.Errorf("%w: dbscan supports at most %d customers, got %d", ErrInvalidParameter, maxPairwiseClusteringPoints, len(points))
+	}
+
+	labels, err := DBSCAN(points, eps, minPts)
+	if err != nil {
+		return nil, err
+	}
+
+	noise := 0
+	for _, label := range labels {
+		if label == NoiseLabel {
+			noise++
+		}
+	}
+
+	return &ClusteringResult{
+		Labels: labels,
+		Details: map[string]interface{}{
+			"eps":         eps,
+			"min_pts":     minPts,
+			"noise_count": noise,
+		},
+	}, nil
+}
+
+// DBSCAN clusters points by density. A point with at least minPts neighbours
+// (itself included) within eps is a core point; clusters grow from core
+// points through their neighbourhoods. Points reachable from no core point are
+// labelled NoiseLabel.
+func DBSCAN(points [][]float64, eps float64, minPts int) ([]int, error) {
+	if eps <= 0 {
+		return nil, fmt.Errorf("%w: eps must be greater than zero", ErrInvalidParameter)
+	}
+	if minPts <= 0 {
+		return nil, fmt.Errorf("%w: min_pts must be greater than zero", ErrInvalidParameter)
+	}
+
+	const unvisited = -2
+
+	labels := make([]int, len(points))
+	for i := range labels {
+		labels[i] = unvisited
+	}
+
+	regionQuery := func(i int) []int {
+		var neighbours []int
+		for j := range points {
+			if euclideanDistance(points[i], points[j]) <= eps {
+				neighbours = append(neighbours, j)
+			}
+		}
+		return neighbours
+	}
+
+	cluster := 0
+	for i := range points {
+		if labels[i] != unvisited {
+			continue
+		}
+
+		neighbours := regionQuery(i)
+		if len(neighbours) < minPts {
+			labels[i] = NoiseLabel
+			continue
+		}
+
+		labels[i] = cluster
+		queue := neighbours
+		for len(queue) > 0 {
+			j := queue[0]
+			queue = queue[1:]
+
+			if labels[j] == NoiseLabel {
+				// Border point: reachable, but does not expand the cluster
+				labels[j] = cluster
+			}
+			if labels[j] != unvisited {
+				continue
+			}
+
+			labels[j] = cluster
+			if jNeighbours := regionQuery(j); len(jNeighbours) >= minPts {
+				queue = append(queue, jNeighbours...)
+			}
+		}
+		cluster++
+	}
+
+	return labels, nil
+}
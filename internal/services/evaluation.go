@@ -0,0 +1,209 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var ErrInvalidEvaluationWindow = errors.New("invalid evaluation window")
+
+// EvaluatePredictions replays history at the cutoff date: customers are scored
+// as they looked at the cutoff, using the active churn model when there is
+// one, and compared with the purchases they made in the window that followed.
+func (s *AnalyticsService) EvaluatePredictions(ctx context.Context, req models.EvaluationRequest) (*models.EvaluationReport, error) {
+	if req.CutoffDate.IsZero() {
+		return nil, fmt.Errorf("%w: cutoff_date is required", ErrInvalidEvaluationWindow)
+	}
+	windowDays := req.WindowDays
+	if windowDays == 0 {
+		windowDays = 90
+	}
+	if windowDays < 0 {
+		return nil, fmt.Errorf("%w: window_days must be positive", ErrInvalidEvaluationWindow)
+	}
+
+	cutoff := req.CutoffDate
+	windowEnd := cutoff.AddDate(0, 0, windowDays)
+	if windowEnd.After(time.Now()) {
+		return nil, fmt.Errorf("%w: the window after the cutoff date must end in the past", ErrInvalidEvaluationWindow)
+	}
+
+	thresholds := req.Thresholds
+	if len(thresholds) == 0 {
+		thresholds = []float64{0.3, 0.5, 0.7}
+	}
+	buckets := req.CalibrationBuckets
+	if buckets <= 0 {
+		buckets = 10
+	}
+
+	churnModel, err := s.activeModel(ctx, churnModelType)
+	if err != nil {
+		return nil, err
+	}
+	if churnModel != nil && churnModel.Logistic == nil {
+		churnModel = nil
+	}
+
+	snapshots, err := s.purchaseSnapshots(ctx, bson.M{}, cutoff, windowEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	// Evaluate the same population the churn model is trained on: customers
+	// who had purchased before the cutoff
+	var scores, labels, predictedDays, actualDays []float64
+	err = s.streamCustomers(ctx, func(customer models.Customer) error {
+		snapshot, ok := snapshots[customer.CustomerID]
+		if !ok || snapshot.Frequency == 0 || !customer.RegistrationDate.Before(cutoff) {
+			return nil
+		}
+		historical := customerAsOf(customer, snapshot)
+
+		var churn models.PredictionResult
+		if churnModel != nil {
+			churn = predictChurnWithModel(historical, churnModel, snapshot, cutoff)
+		} else {
+			churn = s.predictChurn(historical, cutoff)
+		}
+		scores = append(scores, churn.Probability)
+		if snapshot.FuturePurchases == 0 {
+			labels = append(labels, 1)
+		} else {
+			labels = append(labels, 0)
+		}
+
+		if snapshot.NextPurchaseDate != nil {
+			predictedDays = append(predictedDays, s.predictNextPurchase(historical, cutoff).Value)
+			actualDays = append(actualDays, snapshot.NextPurchaseDate.Sub(cutoff).Hours()/24)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.EvaluationReport{
+		CutoffDate:         cutoff,
+		WindowDays:         windowDays,
+		CustomersEvaluated: len(scores),
+		Churn: models.ChurnEvaluation{
+			Thresholds:  []models.ThresholdMetrics{},
+			Calibration: []models.CalibrationBucket{},
+		},
+		NextPurchase: models.NextPurchaseEvaluation{
+			Customers: len(actualDays),
+			MAEDays:   MeanAbsoluteError(predictedDays, actualDays),
+		},
+	}
+	if churnModel != nil {
+		report.Churn.ModelVersion = churnModel.Version
+	}
+	if len(scores) > 0 {
+		var churned float64
+		for _, label := range labels {
+			churned += label
+		}
+		report.Churn.AUC = AUC(scores, labels)
+		report.Churn.LogLoss = LogLoss(scores, labels)
+		report.Churn.ChurnRate = churned / float64(len(labels))
+		report.Churn.Thresholds = ThresholdMetricsAt(scores, labels, thresholds)
+		report.Churn.Calibration = CalibrationBuckets(scores, labels, buckets)
+	}
+
+	return report, nil
+}
+
+// customerAsOf returns the customer with their purchase totals replaced by
+// the values from a historical snapshot.
+func customerAsOf(customer models.Customer, snapshot purchaseSnapshot) models.Customer {
+	customer.PurchaseFrequency = snapshot.Frequency
+	customer.TotalSpent = snapshot.Monetary
+	customer.LastPurchaseDate = snapshot.LastPurchaseDate
+	return customer
+}
+
+// ThresholdMetricsAt returns precision, recall and F1 when every score at or
+// above each threshold is predicted positive.
+func ThresholdMetricsAt(scores, labels, thresholds []float64) []models.ThresholdMetrics {
+	metrics := make([]models.ThresholdMetrics, len(thresholds))
+	for i, threshold := range thresholds {
+		var truePositives, falsePositives, falseNegatives float64
+		for j, score := range scores {
+			positive := labels[j] > 0.5
+			switch {
+			case score >= threshold && positive:
+				truePositives++
+			case score >= threshold:
+				falsePositives++
+			case positive:
+				falseNegatives++
+			}
+		}
+
+		m := models.ThresholdMetrics{Threshold: threshold}
+		if truePositives+falsePositives > 0 {
+			m.Precision = truePositives / (truePositives + falsePositives)
+		}
+		if truePositives+falseNegatives > 0 {
+			m.Recall = truePositives / (truePositives + falseNegatives)
+		}
+		if m.Precision+m.Recall > 0 {
+			m.F1 = 2 * m.Precision * m.Recall / (m.Precision + m.Recall)
+		}
+		metrics[i] = m
+	}
+	return metrics
+}
+
+// CalibrationBuckets splits probabilities into equal-width buckets over [0, 1]
+// and compares the mean prediction with the observed positive rate in each.
+// Empty buckets are omitted.
+func CalibrationBuckets(scores, labels []float64, buckets int) []models.CalibrationBucket {
+	counts := make([]int, buckets)
+	predicted := make([]float64, buckets)
+	observed := make([]float64, buckets)
+	for i, score := range scores {
+		b := min(int(score*float64(buckets)), buckets-1)
+		b = max(b, 0)
+		counts[b]++
+		predicted[b] += score
+		observed[b] += labels[i]
+	}
+
+	result := []models.CalibrationBucket{}
+	width := 1 / float64(buckets)
+	for b, count := range counts {
+		if count == 0 {
+			continue
+		}
+		result = append(result, models.CalibrationBucket{
+			Lower:         float64(b) * width,
+			Upper:         float64(b+1) * width,
+			Count:         count,
+			MeanPredicted: predicted[b] / float64(count),
+			ObservedRate:  observed[b] / float64(count),
+		})
+	}
+	return result
+}
+
+// MeanAbsoluteError returns the mean absolute difference between predictions
+// and actual values, or 0 when there are none.
+func MeanAbsoluteError(predicted, actual []float64) float64 {
+	if len(predicted) == 0 {
+		return 0
+	}
+
+	var total float64
+	for i := range predicted {
+		total += math.Abs(predicted[i] - actual[i])
+	}
+	return total / float64(len(predicted))
+}
@@ -0,0 +1,345 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrInvalidCampaignVariants    = errors.New("invalid campaign variants")
+	ErrInvalidExperimentParameter = errors.New("invalid experiment parameter")
+)
+
+// maxExactBetaTerms bounds the terms summed for the exact probability that one
+// Beta variable exceeds another; past it both are close enough to normal
+const maxExactBetaTerms = 10000
+
+// validateCampaignVariants checks that variant IDs are unique and that at most
+// one variant is the control.
+func validateCampaignVariants(variants []models.CampaignVariant) error {
+	seen := make(map[string]bool, len(variants))
+	var controls int
+	for _, variant := range variants {
+		if seen[variant.VariantID] {
+			return fmt.Errorf("%w: variant %s is listed twice", ErrInvalidCampaignVariants, variant.VariantID)
+		}
+		seen[variant.VariantID] = true
+		if variant.IsControl {
+			controls++
+		}
+	}
+	if controls > 1 {
+		return fmt.Errorf("%w: at most one variant can be the control", ErrInvalidCampaignVariants)
+	}
+	return nil
+}
+
+// TwoProportionZTest tests the difference between the variant's and the
+// control's success rates. The z-score uses the pooled rate, and the interval
+// around the difference at the given confidence level uses the unpooled
+// standard error. Rates and bounds are fractions.
+func TwoProportionZTest(controlSuccesses, controlTrials, variantSuccesses, variantTrials int, confidence float64) (z, pValue, lower, upper float64) {
+	if controlTrials == 0 || variantTrials == 0 {
+		return 0, 1, 0, 0
+	}
+
+	n1, n2 := float64(controlTrials), float64(variantTrials)
+	p1, p2 := float64(controlSuccesses)/n1, float64(variantSuccesses)/n2
+	diff := p2 - p1
+
+	pooled := float64(controlSuccesses+variantSuccesses) / (n1 + n2)
+	pValue = 1
+	if se := math.Sqrt(pooled * (1 - pooled) * (1/n1 + 1/n2)); se > 0 {
+		z = diff / se
+		pValue = 2 * normalCDF(-math.Abs(z))
+	}
+
+	spread := normalQuantile(1-(1-confidence)/2) * math.Sqrt(p1*(1-p1)/n1+p2*(1-p2)/n2)
+	return z, pValue, diff - spread, diff + spread
+}
+
+// ProbabilityToBeatControl returns the probability that the variant's rate
+// exceeds the control's, with uniform Beta priors on both.
+func ProbabilityToBeatControl(controlSuccesses, controlTrials, variantSuccesses, variantTrials int) float64 {
+	aA, bA := float64(controlSuccesses+1), float64(controlTrials-controlSuccesses+1)
+	aB, bB := float64(variantSuccesses+1), float64(variantTrials-variantSuccesses+1)
+
+	var p float64
+	switch {
+	case math.Min(aA, aB) > maxExactBetaTerms:
+		meanA, meanB := aA/(aA+bA), aB/(aB+bB)
+		varA := aA * bA / ((aA + bA) * (aA + bA) * (aA + bA + 1))
+		varB := aB * bB / ((aB + bB) * (aB + bB) * (aB + bB + 1))
+		p = normalCDF((meanB - meanA) / math.Sqrt(varA+varB))
+	case aB <= aA:
+		p = betaExceedsProbability(aA, bA, aB, bB)
+	default:
+		p = 1 - betaExceedsProbability(aB, bB, aA, bA)
+	}
+	return math.Min(math.Max(p, 0), 1)
+}
+
+// betaExceedsProbability returns P(X_B > X_A) for X_A ~ Beta(aA, bA) and
+// X_B ~ Beta(aB, bB) with a whole aB, summing aB terms in log space.
+func betaExceedsProbability(aA, bA, aB, bB float64) float64 {
+	total := math.Inf(-1)
+	for i := 0.0; i < aB; i++ {
+		total = logAddExp(total, lnBeta(aA+i, bA+bB)-math.Log(bB+i)-lnBeta(1+i, bB)-lnBeta(aA, bA))
+	}
+	return math.Exp(total)
+}
+
+// RequiredSampleSize returns the trials needed per variant for a two-sided
+// two-proportion z-test at the confidence level to detect a relative lift over
+// the baseline rate with the given power. It is zero when the lifted rate is
+// not a valid proportion.
+func RequiredSampleSize(baseline, lift, confidence, power float64) int {
+	p1, p2 := baseline, baseline*(1+lift)
+	if p1 <= 0 || p2 >= 1 || p2 == p1 {
+		return 0
+	}
+
+	pooled := (p1 + p2) / 2
+	a := normalQuantile(1-(1-confidence)/2) * math.Sqrt(2*pooled*(1-pooled))
+	b := normalQuantile(power) * math.Sqrt(p1*(1-p1)+p2*(1-p2))
+	return int(math.Ceil((a + b) * (a + b) / ((p2 - p1) * (p2 - p1))))
+}
+
+// ProportionTestPower returns the power of a two-sided two-proportion z-test
+// at the confidence level with n trials per variant to detect a relative lift
+// over the baseline rate.
+func ProportionTestPower(baseline, lift float64, n int, confidence float64) float64 {
+	p1, p2 := baseline, baseline*(1+lift)
+	if n == 0 || p1 <= 0 || p2 >= 1 || p2 == p1 {
+		return 0
+	}
+
+	pooled := (p1 + p2) / 2
+	a := normalQuantile(1-(1-confidence)/2) * math.Sqrt(2*pooled*(1-pooled))
+	return normalCDF((math.Abs(p2-p1)*math.Sqrt(float64(n)) - a) / math.Sqrt(p1*(1-p1)+p2*(1-p2)))
+}
+
+// proportionTest compares a variant's success rate with the control's,
+// reporting rates in percent.
+func proportionTest(controlSuccesses, controlTrials, variantSuccesses, variantTrials int, req models.ExperimentRequest) *models.ProportionTest {
+	z, pValue, lower, upper := TwoProportionZTest(controlSuccesses, controlTrials, variantSuccesses, variantTrials, req.ConfidenceLevel)
+
+	var controlRate, variantRate float64
+	if controlTrials > 0 {
+		controlRate = float64(controlSuccesses) / float64(controlTrials)
+	}
+	if variantTrials > 0 {
+		variantRate = float64(variantSuccesses) / float64(variantTrials)
+	}
+
+	test := &models.ProportionTest{
+		ControlRate:              controlRate * 100,
+		VariantRate:              variantRate * 100,
+		Difference:               (variantRate - controlRate) * 100,
+		ZScore:                   z,
+		PValue:                   pValue,
+		CILower:                  lower * 100,
+		CIUpper:                  upper * 100,
+		Significant:              pValue < 1-req.ConfidenceLevel,
+		ProbabilityToBeatControl: ProbabilityToBeatControl(controlSuccesses, controlTrials, variantSuccesses, variantTrials),
+		RequiredSampleSize:       RequiredSampleSize(controlRate, req.MinDetectableEffect, req.ConfidenceLevel, req.Power),
+		CurrentPower:             ProportionTestPower(controlRate, req.MinDetectableEffect, min(controlTrials, variantTrials), req.ConfidenceLevel),
+	}
+	if controlRate > 0 {
+		test.RelativeLift = variantRate/controlRate - 1
+	}
+	return test
+}
+
+// AnalyzeExperiment totals the performance of each campaign variant and tests
+// its CTR and conversion rate against the control's. Conversion rates are per
+// click, so their sample sizes are in clicks and CTR's in impressions.
+func (s *AnalyticsService) AnalyzeExperiment(ctx context.Context, campaignID string, req models.ExperimentRequest) (*models.ExperimentAnalysis, error) {
+	if req.ConfidenceLevel == 0 {
+		req.ConfidenceLevel = 0.95
+	}
+	if req.ConfidenceLevel <= 0 || req.ConfidenceLevel >= 1 {
+		return nil, fmt.Errorf("%w: confidence must be between 0 and 1", ErrInvalidExperimentParameter)
+	}
+	if req.MinDetectableEffect == 0 {
+		req.MinDetectableEffect = 0.1
+	}
+	if req.MinDetectableEffect < 0 {
+		return nil, fmt.Errorf("%w: mde must be positive", ErrInvalidExperimentParameter)
+	}
+	if req.Power == 0 {
+		req.Power = 0.8
+	}
+	if req.Power <= 0 || req.Power >= 1 {
+		return nil, fmt.Errorf("%w: power must be between 0 and 1", ErrInvalidExperimentParameter)
+	}
+
+	variants, err := s.campaignVariantTotals(ctx, campaignID, req.DateRange)
+	if err != nil {
+		return nil, err
+	}
+
+	control := req.Control
+	if control == "" {
+		for _, variant := range variants {
+			if variant.isControl {
+				control = variant.variantID
+			}
+		}
+	}
+	if control == "" {
+		return nil, fmt.Errorf("%w: campaign %s has no control variant", ErrInvalidExperimentParameter, campaignID)
+	}
+	baseline := -1
+	for i, variant := range variants {
+		if variant.variantID == control {
+			baseline = i
+		}
+	}
+	if baseline < 0 {
+		return nil, fmt.Errorf("%w: unknown control variant %s", ErrInvalidExperimentParameter, control)
+	}
+	if len(variants) < 2 {
+		return nil, fmt.Errorf("%w: campaign %s needs at least two variants", ErrInvalidExperimentParameter, campaignID)
+	}
+
+	analysis := &models.ExperimentAnalysis{
+		CampaignID:          campaignID,
+		Control:             control,
+		ConfidenceLevel:     req.ConfidenceLevel,
+		MinDetectableEffect: req.MinDetectableEffect,
+		Power:               req.Power,
+		Variants:            make([]models.VariantExperiment, len(variants)),
+	}
+	// Clicks come from impressions and conversions from clicks, so neither
+	// can exceed the count it is a rate of
+	for i := range variants {
+		variants[i].clicks = min(variants[i].clicks, variants[i].impressions)
+		variants[i].conversions = min(variants[i].conversions, variants[i].clicks)
+	}
+	base := variants[baseline]
+	for i, variant := range variants {
+		experiment := models.VariantExperiment{
+			VariantID:   variant.variantID,
+			Name:        variant.name,
+			IsControl:   i == baseline,
+			Impressions: variant.impressions,
+			Clicks:      variant.clicks,
+			Conversions: variant.conversions,
+		}
+		if variant.impressions > 0 {
+			experiment.CTR = float64(variant.clicks) / float64(variant.impressions) * 100
+		}
+		if variant.clicks > 0 {
+			experiment.ConversionRate = float64(variant.conversions) / float64(variant.clicks) * 100
+		}
+		if i != baseline {
+			experiment.CTRTest = proportionTest(base.clicks, base.impressions, variant.clicks, variant.impressions, req)
+			experiment.ConversionTest = proportionTest(base.conversions, base.clicks, variant.conversions, variant.clicks, req)
+		}
+		analysis.Variants[i] = experiment
+	}
+
+	return analysis, nil
+}
+
+// variantTotals is the summed performance of one campaign variant
+type variantTotals struct {
+	variantID   string
+	name        string
+	isControl   bool
+	impressions int
+	clicks      int
+	conversions int
+}
+
+// campaignVariantTotals sums the performance of each variant of the campaign
+// over the date range. Declared variants come first in campaign order, even
+// without performance, then any others that have performance.
+func (s *AnalyticsService) campaignVariantTotals(ctx context.Context, campaignID string, dateRange models.DateRange) ([]variantTotals, error) {
+	var campaign models.MarketingCampaign
+	err := s.db.Collection("campaigns").FindOne(ctx, bson.M{"campaign_id": campaignID}).Decode(&campaign)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrCampaignNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get campaign: %w", err)
+	}
+
+	match := bson.M{
+		"campaign_id": campaignID,
+		"variant_id":  bson.M{"$exists": true, "$ne": ""},
+	}
+	date := bson.M{}
+	if !dateRange.StartDate.IsZero() {
+		date["$gte"] = dateRange.StartDate
+	}
+	if !dateRange.EndDate.IsZero() {
+		date["$lte"] = dateRange.EndDate
+	}
+	if len(date) > 0 {
+		match["date"] = date
+	}
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$group": bson.M{
+			"_id":         "$variant_id",
+			"impressions": bson.M{"$sum": "$impressions"},
+			"clicks":      bson.M{"$sum": "$clicks"},
+			"conversions": bson.M{"$sum": "$conversions"},
+		}},
+	}
+	cursor, err := s.db.Collection("campaign_performance").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate variant performance: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	totals := make(map[string]variantTotals)
+	for cursor.Next(ctx) {
+		var row struct {
+			VariantID   string `bson:"_id"`
+			Impressions int    `bson:"impressions"`
+			Clicks      int    `bson:"clicks"`
+			Conversions int    `bson:"conversions"`
+		}
+		if err := cursor.Decode(&row); err != nil {
+			return nil, fmt.Errorf("failed to decode variant performance: %w", err)
+		}
+		totals[row.VariantID] = variantTotals{
+			variantID:   row.VariantID,
+			impressions: row.Impressions,
+			clicks:      row.Clicks,
+			conversions: row.Conversions,
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read variant performance: %w", err)
+	}
+
+	variants := make([]variantTotals, 0, len(campaign.Variants)+len(totals))
+	for _, declared := range campaign.Variants {
+		variant := totals[declared.VariantID]
+		variant.variantID = declared.VariantID
+		variant.name = declared.Name
+		variant.isControl = declared.IsControl
+		variants = append(variants, variant)
+		delete(totals, declared.VariantID)
+	}
+	undeclared := make([]string, 0, len(totals))
+	for variantID := range totals {
+		undeclared = append(undeclared, variantID)
+	}
+	sort.Strings(undeclared)
+	for _, variantID := range undeclared {
+		variants = append(variants, totals[variantID])
+	}
+	return variants, nil
+}
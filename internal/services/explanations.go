@@ -0,0 +1,255 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// customerBaselineID identifies the baseline of all customers in the
+// feature_baselines collection
+const customerBaselineID = "customers"
+
+// customerBaseline describes the average customer the heuristic predictions
+// are explained against
+type customerBaseline struct {
+	DaysSinceLastPurchase float64   `bson:"days_since_last_purchase"`
+	DaysSinceRegistration float64   `bson:"days_since_registration"`
+	PurchaseFrequency     float64   `bson:"purchase_frequency"`
+	TotalSpent            float64   `bson:"total_spent"`
+	ComputedAt            time.Time `bson:"computed_at"`
+}
+
+// add adds a customer's heuristic inputs as of asOf to the running sums.
+// Customers who never purchased count as 365 days since their last purchase,
+// as in predictChurn.
+func (b *customerBaseline) add(customer models.Customer, asOf time.Time) {
+	b.DaysSinceLastPurchase += daysSinceLastPurchase(customer, asOf)
+	b.DaysSinceRegistration += asOf.Sub(customer.RegistrationDate).Hours() / 24
+	b.PurchaseFrequency += float64(customer.PurchaseFrequency)
+	b.TotalSpent += customer.TotalSpent
+}
+
+// saveCustomerBaseline averages the sums over count customers and stores the
+// result as the baseline as of asOf.
+func (s *AnalyticsService) saveCustomerBaseline(ctx context.Context, sums customerBaseline, count int, asOf time.Time) error {
+	n := float64(count)
+	baseline := customerBaseline{
+		DaysSinceLastPurchase: sums.DaysSinceLastPurchase / n,
+		DaysSinceRegistration: sums.DaysSinceRegistration / n,
+		PurchaseFrequency:     sums.PurchaseFrequency / n,
+		TotalSpent:            sums.TotalSpent / n,
+		ComputedAt:            asOf,
+	}
+	_, err := s.db.Collection("feature_baselines").ReplaceOne(ctx, bson.M{"_id": customerBaselineID}, baseline, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to save customer baseline: %w", err)
+	}
+	return nil
+}
+
+// customerBaseline returns the baseline stored when customer features were
+// last computed, with its day counts moved forward to now. It is nil until
+// features have been computed.
+func (s *AnalyticsService) customerBaseline(ctx context.Context, now time.Time) (*customerBaseline, error) {
+	var baseline customerBaseline
+	err := s.db.Collection("feature_baselines").FindOne(ctx, bson.M{"_id": customerBaselineID}).Decode(&baseline)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer baseline: %w", err)
+	}
+
+	elapsed := now.Sub(baseline.ComputedAt).Hours() / 24
+	baseline.DaysSinceLastPurchase += elapsed
+	baseline.DaysSinceRegistration += elapsed
+	return &baseline, nil
+}
+
+// ShapleyValues returns the exact Shapley value of each feature for f at x,
+// where features left out of a coalition take their baseline value. The
+// values sum to f(x) - f(baseline). Cost grows as 2^len(x), so this is only
+// meant for the handful of inputs the heuristics use.
+func ShapleyValues(f func([]float64) float64, x, baseline []float64) []float64 {
+	n := len(x)
+	coalitions := 1 << n
+
+	values := make([]float64, coalitions)
+	point := make([]float64, n)
+	for mask := 0; mask < coalitions; mask++ {
+		for j := 0; j < n; j++ {
+			if mask&(1<<j) != 0 {
+				point[j] = x[j]
+			} else {
+				point[j] = baseline[j]
+			}
+		}
+		values[mask] = f(point)
+	}
+
+	// weights[k] = k!(n-k-1)!/n! for coalitions of size k
+	weights := make([]float64, n)
+	for k := range weights {
+		weights[k] = math.Exp(lnGamma(float64(k+1)) + lnGamma(float64(n-k)) - lnGamma(float64(n+1)))
+	}
+
+	shapley := make([]float64, n)
+	for mask := 0; mask < coalitions; mask++ {
+		size := 0
+		for j := 0; j < n; j++ {
+			if mask&(1<<j) != 0 {
+				size++
+			}
+		}
+		for j := 0; j < n; j++ {
+			if mask&(1<<j) == 0 {
+				shapley[j] += weights[size] * (values[mask|1<<j] - values[mask])
+			}
+		}
+	}
+	return shapley
+}
+
+// shapleyExplanation explains f at x against the baseline.
+func shapleyExplanation(names []string, x, baseline []float64, f func([]float64) float64) *models.PredictionExplanation {
+	contributions := ShapleyValues(f, x, baseline)
+
+	explanation := &models.PredictionExplanation{
+		Method:    "shapley",
+		BaseValue: f(baseline),
+	}
+	for j, name := range names {
+		explanation.Features = append(explanation.Features, models.FeatureContribution{
+			Feature:      name,
+			Value:        x[j],
+			Contribution: contributions[j],
+		})
+	}
+	sortContributions(explanation.Features)
+	return explanation
+}
+
+// explainLogistic attributes a logistic regression score to each feature as
+// coefficient times standardized value, in log-odds.
+func explainLogistic(model *models.LogisticModel, features []float64) *models.PredictionExplanation {
+	scaled := standardizePoint(features, model.Means, model.Stds)
+
+	explanation := &models.PredictionExplanation{
+		Method:    "linear",
+		BaseValue: model.Intercept,
+	}
+	for j, name := range model.FeatureNames {
+		explanation.Features = append(explanation.Features, models.FeatureContribution{
+			Feature:      name,
+			Value:        features[j],
+			Contribution: model.Coefficients[j] * scaled[j],
+		})
+	}
+	sortContributions(explanation.Features)
+	return explanation
+}
+
+func sortContributions(features []models.FeatureContribution) {
+	sort.SliceStable(features, func(a, b int) bool {
+		return math.Abs(features[a].Contribution) > math.Abs(features[b].Contribution)
+	})
+}
+
+// daysAgo returns the time the given number of days before now
+func daysAgo(now time.Time, days float64) time.Time {
+	return now.Add(-time.Duration(days * 24 * float64(time.Hour)))
+}
+
+func daysSinceLastPurchase(customer models.Customer, now time.Time) float64 {
+	if customer.LastPurchaseDate == nil {
+		return 365
+	}
+	return now.Sub(*customer.LastPurchaseDate).Hours() / 24
+}
+
+func (s *AnalyticsService) explainChurnHeuristic(customer models.Customer, baseline customerBaseline, now time.Time) *models.PredictionExplanation {
+	names := []string{"days_since_last_purchase", "purchase_frequency"}
+	x := []float64{daysSinceLastPurchase(customer, now), float64(customer.PurchaseFrequency)}
+	base := []float64{baseline.DaysSinceLastPurchase, baseline.PurchaseFrequency}
+
+	return shapleyExplanation(names, x, base, func(v []float64) float64 {
+		lastPurchase := daysAgo(now, v[0])
+		c := models.Customer{LastPurchaseDate: &lastPurchase, PurchaseFrequency: int(math.Round(v[1]))}
+		return s.predictChurn(c, now).Probability
+	})
+}
+
+func (s *AnalyticsService) explainNextPurchase(customer models.Customer, baseline customerBaseline, now time.Time) *models.PredictionExplanation {
+	names := []string{"days_since_registration", "purchase_frequency", "days_since_last_purchase"}
+	var sinceLastPurchase float64
+	if customer.LastPurchaseDate != nil {
+		sinceLastPurchase = now.Sub(*customer.LastPurchaseDate).Hours() / 24
+	}
+	x := []float64{
+		now.Sub(customer.RegistrationDate).Hours() / 24,
+		float64(customer.PurchaseFrequency),
+		sinceLastPurchase,
+	}
+	base := []float64{baseline.DaysSinceRegistration, baseline.PurchaseFrequency, baseline.DaysSinceLastPurchase}
+
+	return shapleyExplanation(names, x, base, func(v []float64) float64 {
+		lastPurchase := daysAgo(now, v[2])
+		c := models.Customer{
+			RegistrationDate:  daysAgo(now, v[0]),
+			PurchaseFrequency: int(math.Round(v[1])),
+			LastPurchaseDate:  &lastPurchase,
+		}
+		return s.predictNextPurchase(c, now).Value
+	})
+}
+
+func (s *AnalyticsService) explainLifetimeValueHeuristic(customer models.Customer, horizonMonths int, baseline customerBaseline, now time.Time) *models.PredictionExplanation {
+	names := []string{"avg_order_value", "purchase_frequency", "days_since_registration"}
+	var avgOrderValue, baselineOrderValue float64
+	if customer.PurchaseFrequency > 0 {
+		avgOrderValue = customer.TotalSpent / float64(customer.PurchaseFrequency)
+	}
+	if baseline.PurchaseFrequency > 0 {
+		baselineOrderValue = baseline.TotalSpent / baseline.PurchaseFrequency
+	}
+	x := []float64{avgOrderValue, float64(customer.PurchaseFrequency), now.Sub(customer.RegistrationDate).Hours() / 24}
+	base := []float64{baselineOrderValue, baseline.PurchaseFrequency, baseline.DaysSinceRegistration}
+
+	return shapleyExplanation(names, x, base, func(v []float64) float64 {
+		frequency := int(math.Round(v[1]))
+		c := models.Customer{
+			TotalSpent:        v[0] * float64(frequency),
+			PurchaseFrequency: frequency,
+			RegistrationDate:  daysAgo(now, v[2]),
+		}
+		return s.predictLifetimeValue(c, horizonMonths, now).Value
+	})
+}
+
+// explainLifetimeValueModel explains the expected spend from the BG/NBD and
+// Gamma-Gamma models against the mean history of the customers they were
+// trained on.
+func explainLifetimeValueModel(model *models.TrainedModel, history CustomerHistory, horizonMonths int) *models.PredictionExplanation {
+	names := []string{"frequency", "recency_days", "age_days", "monetary_value"}
+	x := []float64{history.Frequency, history.Recency, history.Age, history.MonetaryValue}
+	base := []float64{
+		model.Metrics["mean_frequency"],
+		model.Metrics["mean_recency"],
+		model.Metrics["mean_age"],
+		model.Metrics["mean_monetary_value"],
+	}
+	days := float64(horizonMonths) * daysPerMonth
+
+	return shapleyExplanation(names, x, base, func(v []float64) float64 {
+		h := CustomerHistory{Frequency: v[0], Recency: math.Min(v[1], v[2]), Age: v[2], MonetaryValue: v[3]}
+		return BGNBDExpectedPurchases(*model.BGNBD, h, days) * GammaGammaExpectedValue(*model.GammaGamma, h)
+	})
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrCustomerFeaturesNotFound = errors.New("customer features not computed")
+
+// featureBatchSize is the number of customers whose purchases are aggregated
+// and whose features are written per round trip
+const featureBatchSize = 1000
+
+// storedFeatureExtractors maps the feature store fields (by JSON name) that
+// can be used as segmentation dimensions to their extractors.
+var storedFeatureExtractors = map[string]func(models.CustomerFeatures) float64{
+	"avg_order_value":          func(f models.CustomerFeatures) float64 { return f.AvgOrderValue },
+	"inter_purchase_mean_days": func(f models.CustomerFeatures) float64 { return f.InterPurchaseMeanDays },
+	"inter_purchase_std_days":  func(f models.CustomerFeatures) float64 { return f.InterPurchaseStdDays },
+	"category_diversity":       func(f models.CustomerFeatures) float64 { return float64(f.CategoryDiversity) },
+	"online_ratio":             func(f models.CustomerFeatures) float64 { return f.OnlineRatio },
+	"spend_30d":                func(f models.CustomerFeatures) float64 { return f.Spend30d },
+	"spend_90d":                func(f models.CustomerFeatures) float64 { return f.Spend90d },
+	"spend_365d":               func(f models.CustomerFeatures) float64 { return f.Spend365d },
+}
+
+// InterPurchaseStats returns the mean and population standard deviation of
+// the days between consecutive purchases. Both are 0 with fewer than two
+// purchases.
+func InterPurchaseStats(dates []time.Time) (mean, std float64) {
+	if len(dates) < 2 {
+		return 0, 0
+	}
+
+	sorted := append([]time.Time(nil), dates...)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a].Before(sorted[b]) })
+
+	gaps := make([]float64, len(sorted)-1)
+	for i := 1; i < len(sorted); i++ {
+		gaps[i-1] = sorted[i].Sub(sorted[i-1]).Hours() / 24
+		mean += gaps[i-1]
+	}
+	mean /= float64(len(gaps))
+
+	for _, gap := range gaps {
+		std += (gap - mean) * (gap - mean)
+	}
+	return mean, math.Sqrt(std / float64(len(gaps)))
+}
+
+// deriveCustomerFeatures computes a customer's derived features as of a date
+// from their purchase snapshot at that date.
+func deriveCustomerFeatures(customer models.Customer, snapshot purchaseSnapshot, asOf time.Time) models.CustomerFeatures {
+	features := models.CustomerFeatures{
+		CustomerID:            customer.CustomerID,
+		PurchaseCount:         snapshot.Frequency,
+		DaysSinceRegistration: asOf.Sub(customer.RegistrationDate).Hours() / 24,
+		Spend30d:              snapshot.Spend30d,
+		Spend90d:              snapshot.Spend90d,
+		Spend365d:             snapshot.Spend365d,
+		ComputedAt:            asOf,
+	}
+	if snapshot.Frequency > 0 {
+		features.AvgOrderValue = snapshot.Monetary / float64(snapshot.Frequency)
+		features.OnlineRatio = float64(snapshot.OnlinePurchases) / float64(snapshot.Frequency)
+	}
+
+	var dates []time.Time
+	for _, date := range snapshot.PurchaseDates {
+		if date != nil {
+			dates = append(dates, *date)
+		}
+	}
+	features.InterPurchaseMeanDays, features.InterPurchaseStdDays = InterPurchaseStats(dates)
+
+	for _, category := range snapshot.Categories {
+		if category != nil && *category != "" {
+			features.CategoryDiversity++
+		}
+	}
+
+	return features
+}
+
+// ComputeCustomerFeatures derives the features of every customer from the
+// purchases collection and stores them, replacing earlier values, along with
+// the average customer heuristic predictions are explained against. It
+// returns the number of customers processed.
+func (s *AnalyticsService) ComputeCustomerFeatures(ctx context.Context) (int, time.Time, error) {
+	now := time.Now()
+	var processed int
+	var baseline customerBaseline
+
+	flush := func(customers []models.Customer) error {
+		customerIDs := make([]string, len(customers))
+		for i, customer := range customers {
+			customerIDs[i] = customer.CustomerID
+		}
+		snapshots, err := s.purchaseSnapshots(ctx, bson.M{"customer_id": bson.M{"$in": customerIDs}}, now, now)
+		if err != nil {
+			return err
+		}
+
+		writes := make([]mongo.WriteModel, len(customers))
+		for i, customer := range customers {
+			writes[i] = mongo.NewReplaceOneModel().
+				SetFilter(bson.M{"customer_id": customer.CustomerID}).
+				SetReplacement(deriveCustomerFeatures(customer, snapshots[customer.CustomerID], now)).
+				SetUpsert(true)
+		}
+		if _, err := s.db.Collection("customer_features").BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+			return fmt.Errorf("failed to save customer features: %w", err)
+		}
+
+		processed += len(customers)
+		return nil
+	}
+
+	batch := make([]models.Customer, 0, featureBatchSize)
+	err := s.streamCustomers(ctx, func(customer models.Customer) error {
+		baseline.add(customer, now)
+		batch = append(batch, customer)
+		if len(batch) < featureBatchSize {
+			return nil
+		}
+		err := flush(batch)
+		batch = batch[:0]
+		return err
+	})
+	if err == nil && len(batch) > 0 {
+		err = flush(batch)
+	}
+	if err == nil && processed > 0 {
+		err = s.saveCustomerBaseline(ctx, baseline, processed, now)
+	}
+
+	return processed, now, err
+}
+
+// GetCustomerFeatures returns the stored features of a customer.
+func (s *AnalyticsService) GetCustomerFeatures(ctx context.Context, customerID string) (*models.CustomerFeatures, error) {
+	if err := s.ensureCustomerExists(ctx, customerID); err != nil {
+		return nil, err
+	}
+
+	var features models.CustomerFeatures
+	err := s.db.Collection("customer_features").FindOne(ctx, bson.M{"customer_id": customerID}).Decode(&features)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrCustomerFeaturesNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer features: %w", err)
+	}
+	return &features, nil
+}
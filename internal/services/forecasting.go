@@ -0,0 +1,339 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrInvalidForecastParameter = errors.New("invalid forecast parameter")
+	ErrInsufficientHistory      = errors.New("insufficient history to forecast")
+)
+
+const (
+	forecastIntervalLevel = 0.95
+	forecastIntervalZ     = 1.959964 // Standard normal quantile for forecastIntervalLevel
+	maxForecastHorizon    = 365
+)
+
+// forecastMetrics maps each metric to the purchase field it sums per day
+var forecastMetrics = map[string]string{
+	"revenue": "$amount",
+	"units":   "$quantity",
+}
+
+// forecastGroupFields maps each grouping to the purchase field it splits on
+var forecastGroupFields = map[string]string{
+	"category": "$category",
+	"channel":  "$channel",
+}
+
+// forecastSeasonLengths maps each seasonality to its length in days
+var forecastSeasonLengths = map[string]int{
+	"weekly": 7,
+	"yearly": 365,
+}
+
+// SeriesForecast is the point forecast of a series over a horizon, with the
+// bounds of its prediction interval
+type SeriesForecast struct {
+	Values []float64
+	Lower  []float64
+	Upper  []float64
+	RMSE   float64 // Of the in-sample one-step-ahead errors
+	Alpha  float64 // Smoothing parameters, zero for the seasonal naive method
+	Beta   float64
+	Gamma  float64
+}
+
+// ForecastMetric forecasts a daily purchases metric, in total or per group,
+// with additive Holt-Winters alongside a seasonal naive baseline. Days
+// without purchases count as zero, and the history is trimmed to start at the
+// first day with any.
+func (s *AnalyticsService) ForecastMetric(ctx context.Context, req models.ForecastRequest) (*models.Forecast, error) {
+	metric := req.Metric
+	if metric == "" {
+		metric = "revenue"
+	}
+	metricField, ok := forecastMetrics[metric]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported metric %s", ErrInvalidForecastParameter, metric)
+	}
+	var groupField interface{}
+	if req.GroupBy != "" {
+		field, ok := forecastGroupFields[req.GroupBy]
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported group_by %s", ErrInvalidForecastParameter, req.GroupBy)
+		}
+		groupField = field
+	}
+	seasonality := req.Seasonality
+	if seasonality == "" {
+		seasonality = "weekly"
+	}
+	period, ok := forecastSeasonLengths[seasonality]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported seasonality %s", ErrInvalidForecastParameter, seasonality)
+	}
+
+	horizon := req.Horizon
+	if horizon == 0 {
+		horizon = 30
+	}
+	if horizon < 0 || horizon > maxForecastHorizon {
+		return nil, fmt.Errorf("%w: horizon must be between 1 and %d days", ErrInvalidForecastParameter, maxForecastHorizon)
+	}
+	historyDays := req.HistoryDays
+	if historyDays == 0 {
+		historyDays = 3 * period
+	}
+	if historyDays < 2*period {
+		return nil, fmt.Errorf("%w: history_days must cover at least two seasons (%d days)", ErrInvalidForecastParameter, 2*period)
+	}
+
+	// Fit on whole days up to the start of today
+	now := time.Now().UTC()
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	start := end.AddDate(0, 0, -historyDays)
+
+	pipeline := []bson.M{
+		{"$match": bson.M{"purchase_date": bson.M{"$gte": start, "$lt": end}}},
+		{"$group": bson.M{
+			"_id": bson.M{
+				"day":   bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$purchase_date"}},
+				"group": groupField,
+			},
+			"value": bson.M{"$sum": metricField},
+		}},
+	}
+	cursor, err := s.db.Collection("purchases").Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate daily %s: %w", metric, err)
+	}
+	defer cursor.Close(ctx)
+
+	series := make(map[string][]float64)
+	firstDay := historyDays
+	for cursor.Next(ctx) {
+		var row struct {
+			ID struct {
+				Day   string `bson:"day"`
+				Group string `bson:"group"`
+			} `bson:"_id"`
+			Value float64 `bson:"value"`
+		}
+		if err := cursor.Decode(&row); err != nil {
+			return nil, fmt.Errorf("failed to decode daily %s: %w", metric, err)
+		}
+		day, err := time.Parse("2006-01-02", row.ID.Day)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse day %s: %w", row.ID.Day, err)
+		}
+		index := int(day.Sub(start).Hours() / 24)
+		if index < 0 || index >= historyDays {
+			continue
+		}
+
+		group := row.ID.Group
+		if req.GroupBy != "" && group == "" {
+			group = "unknown"
+		}
+		if series[group] == nil {
+			series[group] = make([]float64, historyDays)
+		}
+		series[group][index] += row.Value
+		firstDay = min(firstDay, index)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read daily %s: %w", metric, err)
+	}
+	if historyDays-firstDay < 2*period {
+		return nil, fmt.Errorf("%w: need at least %d days of purchases for %s seasonality", ErrInsufficientHistory, 2*period, seasonality)
+	}
+
+	groups := make([]string, 0, len(series))
+	for group := range series {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	forecast := &models.Forecast{
+		Metric:        metric,
+		GroupBy:       req.GroupBy,
+		Seasonality:   seasonality,
+		SeasonLength:  period,
+		Horizon:       horizon,
+		IntervalLevel: forecastIntervalLevel,
+		HistoryStart:  start.AddDate(0, 0, firstDay),
+		HistoryEnd:    end,
+		Groups:        make([]models.GroupForecast, 0, len(groups)),
+	}
+	for _, group := range groups {
+		y := series[group][firstDay:]
+		holtWinters := HoltWintersForecast(y, period, horizon, forecastIntervalZ)
+		naive := SeasonalNaiveForecast(y, period, horizon, forecastIntervalZ)
+
+		forecast.Groups = append(forecast.Groups, models.GroupForecast{
+			Group: group,
+			HoltWinters: forecastSeries(holtWinters, end, map[string]float64{
+				"alpha": holtWinters.Alpha,
+				"beta":  holtWinters.Beta,
+				"gamma": holtWinters.Gamma,
+			}),
+			SeasonalNaive: forecastSeries(naive, end, nil),
+		})
+	}
+
+	return forecast, nil
+}
+
+// forecastSeries dates a forecast starting at start. Values and bounds are
+// clamped at zero since purchase metrics cannot be negative.
+func forecastSeries(forecast SeriesForecast, start time.Time, parameters map[string]float64) models.ForecastSeries {
+	series := models.ForecastSeries{
+		Parameters: parameters,
+		RMSE:       forecast.RMSE,
+		Points:     make([]models.ForecastPoint, len(forecast.Values)),
+	}
+	for h := range forecast.Values {
+		series.Points[h] = models.ForecastPoint{
+			Date:  start.AddDate(0, 0, h),
+			Value: math.Max(forecast.Values[h], 0),
+			Lower: math.Max(forecast.Lower[h], 0),
+			Upper: math.Max(forecast.Upper[h], 0),
+		}
+	}
+	return series
+}
+
+// holtWintersState is the state of additive Holt-Winters smoothing after a
+// pass over a series
+type holtWintersState struct {
+	level    float64
+	trend    float64
+	seasonal []float64 // Indexed by time modulo the period
+	sse      float64   // Of the one-step-ahead errors
+	steps    int       // Number of one-step-ahead errors
+}
+
+// runHoltWinters smooths y with additive level, trend and seasonality. The
+// state is initialized from the first two seasons, so y needs at least
+// 2*period values, and errors are counted from the second season on.
+func runHoltWinters(y []float64, period int, alpha, beta, gamma float64) holtWintersState {
+	var first, second float64
+	for i := 0; i < period; i++ {
+		first += y[i]
+		second += y[period+i]
+	}
+	first /= float64(period)
+	second /= float64(period)
+
+	state := holtWintersState{
+		level:    first,
+		trend:    (second - first) / float64(period),
+		seasonal: make([]float64, period),
+	}
+	for i := 0; i < period; i++ {
+		state.seasonal[i] = y[i] - first
+	}
+
+	for t := period; t < len(y); t++ {
+		season := t % period
+		e := y[t] - (state.level + state.trend + state.seasonal[season])
+		state.sse += e * e
+		state.steps++
+
+		level := alpha*(y[t]-state.seasonal[season]) + (1-alpha)*(state.level+state.trend)
+		state.trend = beta*(level-state.level) + (1-beta)*state.trend
+		state.seasonal[season] = gamma*(y[t]-level) + (1-gamma)*state.seasonal[season]
+		state.level = level
+	}
+	return state
+}
+
+// HoltWintersForecast fits additive Holt-Winters to y by minimizing the
+// one-step-ahead squared error and forecasts horizon steps ahead. Interval
+// bounds are z standard deviations of the h-step error around the forecast.
+// y needs at least 2*period values.
+func HoltWintersForecast(y []float64, period, horizon int, z float64) SeriesForecast {
+	sigmoid := func(x float64) float64 { return 1 / (1 + math.Exp(-x)) }
+	logit := func(p float64) float64 { return math.Log(p / (1 - p)) }
+
+	best := nelderMead(func(x []float64) float64 {
+		return runHoltWinters(y, period, sigmoid(x[0]), sigmoid(x[1]), sigmoid(x[2])).sse
+	}, []float64{logit(0.3), logit(0.05), logit(0.1)}, 500, 1e-10)
+	alpha, beta, gamma := sigmoid(best[0]), sigmoid(best[1]), sigmoid(best[2])
+	state := runHoltWinters(y, period, alpha, beta, gamma)
+
+	forecast := SeriesForecast{
+		Values: make([]float64, horizon),
+		Lower:  make([]float64, horizon),
+		Upper:  make([]float64, horizon),
+		Alpha:  alpha,
+		Beta:   beta,
+		Gamma:  gamma,
+	}
+	if state.steps > 0 {
+		forecast.RMSE = math.Sqrt(state.sse / float64(state.steps))
+	}
+
+	// The h-step error variance is sigma^2 (1 + sum_{j<h} c_j^2) with
+	// c_j = alpha (1 + j beta) + gamma when j is a whole number of seasons
+	variance := 1.0
+	n := len(y)
+	for h := 1; h <= horizon; h++ {
+		if h > 1 {
+			j := h - 1
+			c := alpha * (1 + float64(j)*beta)
+			if j%period == 0 {
+				c += gamma
+			}
+			variance += c * c
+		}
+		value := state.level + float64(h)*state.trend + state.seasonal[(n+h-1)%period]
+		spread := z * forecast.RMSE * math.Sqrt(variance)
+		forecast.Values[h-1] = value
+		forecast.Lower[h-1] = value - spread
+		forecast.Upper[h-1] = value + spread
+	}
+	return forecast
+}
+
+// SeasonalNaiveForecast forecasts each step as the value one season earlier.
+// The h-step error grows with the square root of the number of seasons
+// forecast ahead. y needs at least 2*period values.
+func SeasonalNaiveForecast(y []float64, period, horizon int, z float64) SeriesForecast {
+	var sse float64
+	for t := period; t < len(y); t++ {
+		e := y[t] - y[t-period]
+		sse += e * e
+	}
+
+	forecast := SeriesForecast{
+		Values: make([]float64, horizon),
+		Lower:  make([]float64, horizon),
+		Upper:  make([]float64, horizon),
+	}
+	if len(y) > period {
+		forecast.RMSE = math.Sqrt(sse / float64(len(y)-period))
+	}
+
+	n := len(y)
+	for h := 1; h <= horizon; h++ {
+		seasons := (h - 1) / period
+		value := y[n-period+(h-1)%period]
+		spread := z * forecast.RMSE * math.Sqrt(float64(seasons+1))
+		forecast.Values[h-1] = value
+		forecast.Lower[h-1] = value - spread
+		forecast.Upper[h-1] = value + spread
+	}
+	return forecast
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrInvalidEvents          = errors.New("invalid events")
+	ErrInvalidFunnelParameter = errors.New("invalid funnel parameter")
+)
+
+// maxEventBatchSize bounds the number of events recorded per request
+const maxEventBatchSize = 1000
+
+// funnelEventTypes lists the event types that can be used as funnel steps
+var funnelEventTypes = map[string]bool{
+	"view":        true,
+	"add_to_cart": true,
+	"checkout":    true,
+	"purchase":    true,
+}
+
+// TrackEvents records a batch of events and returns how many were saved.
+// Events without a timestamp are stamped with the current time.
+func (s *AnalyticsService) TrackEvents(ctx context.Context, events []models.Event) (int, error) {
+	if len(events) == 0 {
+		return 0, fmt.Errorf("%w: no events given", ErrInvalidEvents)
+	}
+	if len(events) > maxEventBatchSize {
+		return 0, fmt.Errorf("%w: at most %d events per batch", ErrInvalidEvents, maxEventBatchSize)
+	}
+
+	now := time.Now()
+	docs := make([]interface{}, len(events))
+	for i, event := range events {
+		event.ID = primitive.NewObjectID()
+		if event.Timestamp.IsZero() {
+			event.Timestamp = now
+		}
+		event.CreatedAt = now
+		docs[i] = event
+	}
+
+	if _, err := s.db.Collection("events").InsertMany(ctx, docs, options.InsertMany().SetOrdered(false)); err != nil {
+		return 0, fmt.Errorf("failed to save events: %w", err)
+	}
+	return len(docs), nil
+}
+
+// FunnelProgress returns the time at which the customer reached each funnel
+// step, for as many steps as they reached. Every occurrence of the first step
+// starts an attempt that takes the earliest next step after the previous one
+// within the window of the attempt's start; the attempt reaching the most
+// steps wins, the earliest on ties. Events must be sorted by timestamp. The
+// second result is the index of the event that started the winning attempt.
+func FunnelProgress(events []models.Event, steps []string, window time.Duration) ([]time.Time, int) {
+	var best []time.Time
+	bestStart := -1
+	for start, event := range events {
+		if event.EventType != steps[0] {
+			continue
+		}
+
+		reached := []time.Time{event.Timestamp}
+		deadline := event.Timestamp.Add(window)
+		for i := start + 1; i < len(events) && len(reached) < len(steps); i++ {
+			if events[i].Timestamp.After(deadline) {
+				break
+			}
+			if events[i].EventType == steps[len(reached)] {
+				reached = append(reached, events[i].Timestamp)
+			}
+		}
+
+		if len(reached) > len(best) {
+			best, bestStart = reached, start
+			if len(best) == len(steps) {
+				break
+			}
+		}
+	}
+	return best, bestStart
+}
+
+// funnelCounter accumulates the progress of the customers of one group
+type funnelCounter struct {
+	customers []int
+	durations [][]float64 // Seconds from the previous step, per step
+}
+
+func newFunnelCounter(steps int) *funnelCounter {
+	return &funnelCounter{customers: make([]int, steps), durations: make([][]float64, steps)}
+}
+
+func (f *funnelCounter) add(reached []time.Time) {
+	for i, at := range reached {
+		f.customers[i]++
+		if i > 0 {
+			f.durations[i] = append(f.durations[i], at.Sub(reached[i-1]).Seconds())
+		}
+	}
+}
+
+func (f *funnelCounter) group(segment string, steps []string) models.FunnelGroup {
+	group := models.FunnelGroup{Segment: segment, Steps: make([]models.FunnelStep, len(steps))}
+	for i, step := range steps {
+		funnelStep := models.FunnelStep{Step: step, Customers: f.customers[i]}
+		if f.customers[0] > 0 {
+			funnelStep.ConversionRate = float64(f.customers[i]) / float64(f.customers[0])
+		}
+		if i > 0 {
+			if f.customers[i-1] > 0 {
+				funnelStep.DropOffRate = 1 - float64(f.customers[i])/float64(f.customers[i-1])
+			}
+			funnelStep.MedianSecondsFromPrevious = percentile(f.durations[i], 0.5)
+		}
+		group.Steps[i] = funnelStep
+	}
+	group.OverallConversion = group.Steps[len(steps)-1].ConversionRate
+	return group
+}
+
+// AnalyzeFunnel counts the customers who went through the funnel steps in
+// order within the conversion window, overall and optionally per channel of
+// the event that entered the funnel or per customer segment.
+func (s *AnalyticsService) AnalyzeFunnel(ctx context.Context, req models.FunnelRequest) (*models.FunnelResult, error) {
+	if len(req.Steps) < 2 {
+		return nil, fmt.Errorf("%w: at least two steps are required", ErrInvalidFunnelParameter)
+	}
+	for _, step := range req.Steps {
+		if !funnelEventTypes[step] {
+			return nil, fmt.Errorf("%w: unsupported step %s", ErrInvalidFunnelParameter, step)
+		}
+	}
+	windowHours := req.WindowHours
+	if windowHours == 0 {
+		windowHours = 24
+	}
+	if windowHours < 0 {
+		return nil, fmt.Errorf("%w: window_hours must be positive", ErrInvalidFunnelParameter)
+	}
+
+	result := &models.FunnelResult{
+		Steps:       req.Steps,
+		WindowHours: windowHours,
+		SegmentBy:   req.SegmentBy,
+	}
+
+	var assignments, segmentNames map[string]string
+	switch req.SegmentBy {
+	case "", "channel":
+	case "segment":
+		runID, err := s.funnelRunID(ctx, req.RunID)
+		if err != nil {
+			return nil, err
+		}
+		if assignments, err = s.runAssignments(ctx, runID); err != nil {
+			return nil, err
+		}
+		segments, err := s.getRunSegments(ctx, runID)
+		if err != nil {
+			return nil, err
+		}
+		segmentNames = make(map[string]string, len(segments))
+		for _, segment := range segments {
+			segmentNames[segment.SegmentID] = segment.Name
+		}
+		result.RunID = runID
+	default:
+		return nil, fmt.Errorf("%w: unsupported segment_by %s", ErrInvalidFunnelParameter, req.SegmentBy)
+	}
+
+	match := bson.M{"event_type": bson.M{"$in": req.Steps}}
+	if req.DateRange != nil {
+		timestamp := bson.M{}
+		if !req.DateRange.StartDate.IsZero() {
+			timestamp["$gte"] = req.DateRange.StartDate
+		}
+		if !req.DateRange.EndDate.IsZero() {
+			timestamp["$lte"] = req.DateRange.EndDate
+		}
+		if len(timestamp) > 0 {
+			match["timestamp"] = timestamp
+		}
+	}
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$sort": bson.D{{Key: "customer_id", Value: 1}, {Key: "timestamp", Value: 1}}},
+		{"$group": bson.M{
+			"_id": "$customer_id",
+			"events": bson.M{"$push": bson.M{
+				"event_type": "$event_type",
+				"channel":    "$channel",
+				"timestamp":  "$timestamp",
+			}},
+		}},
+	}
+	cursor, err := s.db.Collection("events").Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate funnel events: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	window := time.Duration(windowHours) * time.Hour
+	overall := newFunnelCounter(len(req.Steps))
+	groups := make(map[string]*funnelCounter)
+	for cursor.Next(ctx) {
+		var customer struct {
+			CustomerID string         `bson:"_id"`
+			Events     []models.Event `bson:"events"`
+		}
+		if err := cursor.Decode(&customer); err != nil {
+			return nil, fmt.Errorf("failed to decode funnel events: %w", err)
+		}
+
+		reached, start := FunnelProgress(customer.Events, req.Steps, window)
+		if len(reached) == 0 {
+			continue
+		}
+		overall.add(reached)
+
+		var segment string
+		switch req.SegmentBy {
+		case "channel":
+			segment = customer.Events[start].Channel
+		case "segment":
+			segment = segmentNames[assignments[customer.CustomerID]]
+		default:
+			continue
+		}
+		if segment == "" {
+			segment = "unknown"
+		}
+		if groups[segment] == nil {
+			groups[segment] = newFunnelCounter(len(req.Steps))
+		}
+		groups[segment].add(reached)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read funnel events: %w", err)
+	}
+
+	result.Groups = append(result.Groups, overall.group("", req.Steps))
+	segments := make([]string, 0, len(groups))
+	for segment := range groups {
+		segments = append(segments, segment)
+	}
+	sort.Strings(segments)
+	for _, segment := range segments {
+		result.Groups = append(result.Groups, groups[segment].group(segment, req.Steps))
+	}
+
+	return result, nil
+}
+
+// funnelRunID returns the given segmentation run ID after checking it exists
+// and has completed, or the latest completed run's when empty.
+func (s *AnalyticsService) funnelRunID(ctx context.Context, runID string) (string, error) {
+	filter := bson.M{"status": bson.M{"$ne": segmentationRunPending}}
+	if runID != "" {
+		filter["run_id"] = runID
+	}
+
+	var run models.SegmentationRun
+	opts := options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	err := s.db.Collection("segmentation_runs").FindOne(ctx, filter, opts).Decode(&run)
+	if err == mongo.ErrNoDocuments {
+		return "", ErrSegmentationRunNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get segmentation run: %w", err)
+	}
+	return run.RunID, nil
+}
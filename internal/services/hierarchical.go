@@ -0,0 +1,158 @@
+package services
+
+import (
+	"fmt"
+	"math"
+)
+
+type hierarchicalAlgorithm struct{}
+
+func (hierarchicalAlgorithm) Cluster(points [][]float64, params map[string]interface{}) (*ClusteringResult, error) {
+	linkage := "average"
+	if v, ok := params["linkage"].(string); ok && v != "" {
+		linkage = v
+	}
+
+	if len(points) > maxPairwiseClusteringPoints {
+		return nil, fmt.Errorf("%w: hierarchical clustering supports at most %d customers, got %d", ErrInvalidParameter, maxPairwiseClusteringPoints, len(points))
+	}
+
+	labels, err := AgglomerativeClustering(points, paramInt(params, "k", 3), linkage, paramFloat(params, "distance_threshold", 0))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ClusteringResult{
+		Labels:  labels,
+		Details: map[string]interface{}{"linkage": linkage},
+	}, nil
+}
+
+// AgglomerativeClustering merges points bottom-up until k clusters remain, or,
+// when distanceThreshold is positive, until the closest pair of clusters is
+// further apart than the threshold. Supported linkages are single, complete,
+// average and ward; cluster distances are updated with the Lance-Williams
+// formula.
+func AgglomerativeClustering(points [][]float64, k int, linkage string, distanceThreshold float64) ([]int, error) {
+	if k <= 0 && distanceThreshold <= 0 {
+		return nil, fmt.Errorf("%w: k or distance_threshold must be greater than zero", ErrInvalidParameter)
+	}
+	if len(points) < k {
+		return nil, fmt.Errorf("%w: not enough data points (%d) for %d clusters", ErrInvalidParameter, len(points), k)
+	}
+
+	var update func(dim, djm, dij float64, ni, nj, nm int) float64
+	switch linkage {
+	case "single":
+		update = func(dim, djm, _ float64, _, _, _ int) float64 { return math.Min(dim, djm) }
+	case "complete":
+		update = func(dim, djm, _ float64, _, _, _ int) float64 { return math.Max(dim, djm) }
+	case "average":
+		update = func(dim, djm, _ float64, ni, nj, _ int) float64 {
+			return (float64(ni)*dim + float64(nj)*djm) / float64(ni+nj)
+		}
+	case "ward":
+		update = func(dim, djm, dij float64, ni, nj, nm int) float64 {
+			return (float64(ni+nm)*dim + float64(nj+nm)*djm - float64(nm)*dij) / float64(ni+nj+nm)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported linkage %s", ErrInvalidParameter, linkage)
+	}
+
+	n := len(points)
+	dist := make([][]float64, n)
+	for i := range dist {
+		dist[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			d := euclideanDistance(points[i], points[j])
+			if linkage == "ward" {
+				d *= d
+			}
+			dist[i][j], dist[j][i] = d, d
+		}
+	}
+
+	active := make([]bool, n)
+	sizes := make([]int, n)
+	members := make([][]int, n)
+	for i := range active {
+		active[i] = true
+		sizes[i] = 1
+		members[i] = []int{i}
+	}
+
+	// Cache every cluster's nearest neighbour to avoid a full scan per merge
+	nn := make([]int, n)
+	nnDist := make([]float64, n)
+	refresh := func(i int) {
+		nn[i], nnDist[i] = -1, math.Inf(1)
+		for j := 0; j < n; j++ {
+			if j != i && active[j] && dist[i][j] < nnDist[i] {
+				nn[i], nnDist[i] = j, dist[i][j]
+			}
+		}
+	}
+	for i := range nn {
+		refresh(i)
+	}
+
+	remaining := n
+	for remaining > 1 && remaining > k {
+		i := -1
+		for c := 0; c < n; c++ {
+			if active[c] && nn[c] >= 0 && (i < 0 || nnDist[c] < nnDist[i]) {
+				i = c
+			}
+		}
+		j := nn[i]
+
+		height := nnDist[i]
+		if linkage == "ward" {
+			height = math.Sqrt(height)
+		}
+		if distanceThreshold > 0 && height > distanceThreshold {
+			break
+		}
+
+		for m := 0; m < n; m++ {
+			if !active[m] || m == i || m == j {
+				continue
+			}
+			d := update(dist[i][m], dist[j][m], dist[i][j], sizes[i], sizes[j], sizes[m])
+			dist[i][m], dist[m][i] = d, d
+		}
+
+		active[j] = false
+		sizes[i] += sizes[j]
+		members[i] = append(members[i], members[j]...)
+		members[j] = nil
+		remaining--
+
+		for m := 0; m < n; m++ {
+			if !active[m] {
+				continue
+			}
+			if m == i || nn[m] == i || nn[m] == j {
+				refresh(m)
+			} else if dist[m][i] < nnDist[m] {
+				nn[m], nnDist[m] = i, dist[m][i]
+			}
+		}
+	}
+
+	labels := make([]int, n)
+	label := 0
+	for c := 0; c < n; c++ {
+		if !active[c] {
+			continue
+		}
+		for _, p := range members[c] {
+			labels[p] = label
+		}
+		label++
+	}
+
+	return labels, nil
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"errors"
+	"math"
+	"sort"
+)
+
+// LogisticConfig holds the tuning parameters for TrainLogisticRegression.
+type LogisticConfig struct {
+	LearningRate float64
+	Iterations   int
+	L2           float64
+}
+
+// TrainLogisticRegression fits a logistic regression with L2 regularisation
+// by batch gradient descent. Features are standardized first; the scaling is
+// stored on the model so raw feature vectors can be scored later.
+func TrainLogisticRegression(features [][]float64, labels []float64, featureNames []string, cfg LogisticConfig) (*models.LogisticModel, error) {
+	if len(features) == 0 {
+		return nil, errors.New("no training samples")
+	}
+	if len(features) != len(labels) {
+		return nil, errors.New("features and labels must have the same length")
+	}
+	if cfg.LearningRate <= 0 {
+		cfg.LearningRate = 0.1
+	}
+	if cfg.Iterations <= 0 {
+		cfg.Iterations = 1000
+	}
+
+	scaled, means, stds := standardize(features)
+	dims := len(featureNames)
+	n := float64(len(scaled))
+
+	weights := make([]float64, dims)
+	var intercept float64
+	gradient := make([]float64, dims)
+
+	for iter := 0; iter < cfg.Iterations; iter++ {
+		for j := range gradient {
+			gradient[j] = 0
+		}
+		var interceptGradient float64
+
+		for i, x := range scaled {
+			err := sigmoid(dot(weights, x)+intercept) - labels[i]
+			for j, v := range x {
+				gradient[j] += err * v
+			}
+			interceptGradient += err
+		}
+
+		for j := range weights {
+			weights[j] -= cfg.LearningRate * (gradient[j]/n + cfg.L2*weights[j])
+		}
+		intercept -= cfg.LearningRate * interceptGradient / n
+	}
+
+	return &models.LogisticModel{
+		FeatureNames: featureNames,
+		Coefficients: weights,
+		Intercept:    intercept,
+		Means:        means,
+		Stds:         stds,
+	}, nil
+}
+
+// PredictLogistic returns the positive-class probability for a raw (not
+// standardized) feature vector.
+func PredictLogistic(model *models.LogisticModel, features []float64) float64 {
+	scaled := standardizePoint(features, model.Means, model.Stds)
+	return sigmoid(dot(model.Coefficients, scaled) + model.Intercept)
+}
+
+func sigmoid(z float64) float64 {
+	return 1 / (1 + math.Exp(-z))
+}
+
+func dot(a, b []float64) float64 {
+	var sum float64
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// AUC returns the area under the ROC curve for binary labels (0 or 1), using
+// the rank-sum formulation with tied scores sharing their average rank.
+// Returns 0.5 when only one class is present.
+func AUC(scores, labels []float64) float64 {
+	order := make([]int, len(scores))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return scores[order[a]] < scores[order[b]] })
+
+	ranks := make([]float64, len(scores))
+	for i := 0; i < len(order); {
+		j := i
+		for j+1 < len(order) && scores[order[j+1]] == scores[order[i]] {
+			j++
+		}
+		avgRank := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			ranks[order[k]] = avgRank
+		}
+		i = j + 1
+	}
+
+	var positives, negatives, positiveRankSum float64
+	for i, label := range labels {
+		if label > 0.5 {
+			positives++
+			positiveRankSum += ranks[i]
+		} else {
+			negatives++
+		}
+	}
+	if positives == 0 || negatives == 0 {
+		return 0.5
+	}
+
+	return (positiveRankSum - positives*(positives+1)/2) / (positives * negatives)
+}
+
+// LogLoss returns the mean binary cross-entropy of the predicted
+// probabilities.
+func LogLoss(probabilities, labels []float64) float64 {
+	if len(probabilities) == 0 {
+		return 0
+	}
+
+	const eps = 1e-15
+	var loss float64
+	for i, p := range probabilities {
+		p = math.Min(math.Max(p, eps), 1-eps)
+		loss -= labels[i]*math.Log(p) + (1-labels[i])*math.Log(1-p)
+	}
+	return loss / float64(len(probabilities))
+}
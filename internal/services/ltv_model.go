@@ -0,0 +1,403 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	ltvModelType = "ltv"
+
+	daysPerMonth = 365.25 / 12
+
+	// ltvSimulations is the number of posterior draws used for prediction
+	// intervals, which cover ltvIntervalLevel of the simulated outcomes
+	ltvSimulations   = 2000
+	ltvIntervalLevel = 0.9
+)
+
+var ErrInvalidHorizon = errors.New("invalid prediction horizon")
+
+// CustomerHistory summarizes a customer's purchases in the form used by the
+// BG/NBD and Gamma-Gamma models. Purchases on the same day count as one
+// transaction and all times are in days.
+type CustomerHistory struct {
+	Frequency     float64 // Repeat transactions after the first one
+	Recency       float64 // Days between the first and the last transaction
+	Age           float64 // Days between the first transaction and the as-of date
+	MonetaryValue float64 // Mean spend of the repeat transactions
+}
+
+// transactionSummary is the per-customer aggregate customerHistories reads
+// from the purchases collection
+type transactionSummary struct {
+	CustomerID    string    `bson:"_id"`
+	Transactions  int       `bson:"transactions"`
+	FirstPurchase time.Time `bson:"first_purchase"`
+	LastPurchase  time.Time `bson:"last_purchase"`
+	Total         float64   `bson:"total"`
+	FirstAmount   float64   `bson:"first_amount"`
+}
+
+// customerHistories builds the repeat-purchase history of every customer with
+// purchases matching filter, as of asOf.
+func (s *AnalyticsService) customerHistories(ctx context.Context, filter bson.M, asOf time.Time) (map[string]CustomerHistory, error) {
+	pipeline := []bson.M{
+		{"$match": filter},
+		{"$group": bson.M{
+			"_id": bson.M{
+				"customer_id": "$customer_id",
+				"day":         bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$purchase_date"}},
+			},
+			"date":   bson.M{"$min": "$purchase_date"},
+			"amount": bson.M{"$sum": "$amount"},
+		}},
+		{"$sort": bson.M{"date": 1}},
+		{"$group": bson.M{
+			"_id":            "$_id.customer_id",
+			"transactions":   bson.M{"$sum": 1},
+			"first_purchase": bson.M{"$first": "$date"},
+			"last_purchase":  bson.M{"$last": "$date"},
+			"total":          bson.M{"$sum": "$amount"},
+			"first_amount":   bson.M{"$first": "$amount"},
+		}},
+	}
+
+	cursor, err := s.db.Collection("purchases").Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate purchase history: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	histories := make(map[string]CustomerHistory)
+	for cursor.Next(ctx) {
+		var summary transactionSummary
+		if err := cursor.Decode(&summary); err != nil {
+			return nil, fmt.Errorf("failed to decode purchase history: %w", err)
+		}
+
+		history := CustomerHistory{
+			Frequency: float64(summary.Transactions - 1),
+			Recency:   summary.LastPurchase.Sub(summary.FirstPurchase).Hours() / 24,
+			Age:       math.Max(0, asOf.Sub(summary.FirstPurchase).Hours()/24),
+		}
+		if history.Frequency > 0 {
+			history.MonetaryValue = (summary.Total - summary.FirstAmount) / history.Frequency
+		}
+		histories[summary.CustomerID] = history
+	}
+
+	return histories, cursor.Err()
+}
+
+// BGNBDLogLikelihood returns the BG/NBD log-likelihood of one customer's
+// history.
+func BGNBDLogLikelihood(model models.BGNBDModel, h CustomerHistory) float64 {
+	r, alpha, a, b := model.R, model.Alpha, model.A, model.B
+	x := h.Frequency
+
+	ll := lnGamma(r+x) - lnGamma(r) + r*math.Log(alpha) +
+		lnBeta(a, b+x) - lnBeta(a, b)
+
+	alive := -(r + x) * math.Log(alpha+h.Age)
+	dropped := math.Inf(-1)
+	if x > 0 {
+		dropped = math.Log(a) - math.Log(b+x-1) - (r+x)*math.Log(alpha+h.Recency)
+	}
+	return ll + logAddExp(alive, dropped)
+}
+
+// BGNBDProbabilityAlive returns the probability that a customer with the given
+// history is still active.
+func BGNBDProbabilityAlive(model models.BGNBDModel, h CustomerHistory) float64 {
+	if h.Frequency == 0 {
+		return 1
+	}
+	x := h.Frequency
+	ratio := (model.R + x) * math.Log((model.Alpha+h.Age)/(model.Alpha+h.Recency))
+	return 1 / (1 + model.A/(model.B+x-1)*math.Exp(ratio))
+}
+
+// BGNBDExpectedPurchases returns the expected number of transactions in the
+// next days for a customer with the given history. It is only defined for a
+// above 1, which FitBGNBD guarantees.
+func BGNBDExpectedPurchases(model models.BGNBDModel, h CustomerHistory, days float64) float64 {
+	r, alpha, a, b := model.R, model.Alpha, model.A, model.B
+	x := h.Frequency
+
+	z := days / (alpha + h.Age + days)
+	decay := math.Exp((r + x) * math.Log((alpha+h.Age)/(alpha+h.Age+days)))
+	numerator := (a + b + x - 1) / (a - 1) * (1 - decay*hyp2f1(r+x, b+x, a+b+x-1, z))
+
+	denominator := 1.0
+	if x > 0 {
+		denominator += a / (b + x - 1) * math.Exp((r+x)*math.Log((alpha+h.Age)/(alpha+h.Recency)))
+	}
+
+	expected := numerator / denominator
+	if math.IsNaN(expected) || expected < 0 {
+		return 0
+	}
+	return expected
+}
+
+// GammaGammaLogLikelihood returns the Gamma-Gamma log-likelihood of a repeat
+// customer's mean transaction value, up to a constant.
+func GammaGammaLogLikelihood(model models.GammaGammaModel, h CustomerHistory) float64 {
+	p, q, gamma := model.P, model.Q, model.Gamma
+	x, m := h.Frequency, h.MonetaryValue
+
+	return lnGamma(p*x+q) - lnGamma(p*x) - lnGamma(q) + q*math.Log(gamma) +
+		(p*x-1)*math.Log(m) + p*x*math.Log(x) - (p*x+q)*math.Log(x*m+gamma)
+}
+
+// GammaGammaExpectedValue returns the expected spend per transaction for a
+// customer, shrinking their observed mean towards the population mean.
+func GammaGammaExpectedValue(model models.GammaGammaModel, h CustomerHistory) float64 {
+	p, q, gamma := model.P, model.Q, model.Gamma
+	if h.Frequency == 0 {
+		return p * gamma / (q - 1)
+	}
+	return p * (gamma + h.Frequency*h.MonetaryValue) / (p*h.Frequency + q - 1)
+}
+
+// FitBGNBD estimates BG/NBD parameters by maximum likelihood. Parameters are
+// searched on a log scale so they stay positive, and a is kept above 1 so the
+// expected number of purchases is defined.
+func FitBGNBD(histories []CustomerHistory, maxIterations int) (*models.BGNBDModel, error) {
+	if len(histories) == 0 {
+		return nil, errors.New("no customer histories")
+	}
+
+	var meanAge float64
+	for _, h := range histories {
+		meanAge += h.Age / float64(len(histories))
+	}
+
+	toModel := func(theta []float64) models.BGNBDModel {
+		return models.BGNBDModel{
+			R:     math.Exp(theta[0]),
+			Alpha: math.Exp(theta[1]),
+			A:     1 + math.Exp(theta[2]),
+			B:     math.Exp(theta[3]),
+		}
+	}
+	negLogLikelihood := func(theta []float64) float64 {
+		model := toModel(theta)
+		var ll float64
+		for _, h := range histories {
+			ll += BGNBDLogLikelihood(model, h)
+		}
+		return -ll
+	}
+
+	start := []float64{0, math.Log(math.Max(meanAge, 1)), 0, 0}
+	theta := nelderMead(negLogLikelihood, start, maxIterations, 1e-8)
+	if math.IsInf(safeEval(negLogLikelihood, theta), 0) {
+		return nil, errors.New("BG/NBD fit did not converge")
+	}
+
+	model := toModel(theta)
+	return &model, nil
+}
+
+// FitGammaGamma estimates Gamma-Gamma parameters by maximum likelihood from
+// customers with repeat transactions. Q is kept above 1 so the expected spend
+// per transaction is defined.
+func FitGammaGamma(histories []CustomerHistory, maxIterations int) (*models.GammaGammaModel, error) {
+	var repeat []CustomerHistory
+	var meanValue float64
+	for _, h := range histories {
+		if h.Frequency > 0 && h.MonetaryValue > 0 {
+			repeat = append(repeat, h)
+			meanValue += h.MonetaryValue
+		}
+	}
+	if len(repeat) == 0 {
+		return nil, errors.New("no repeat customers")
+	}
+	meanValue /= float64(len(repeat))
+
+	toModel := func(theta []float64) models.GammaGammaModel {
+		return models.GammaGammaModel{
+			P:     math.Exp(theta[0]),
+			Q:     1 + math.Exp(theta[1]),
+			Gamma: math.Exp(theta[2]),
+		}
+	}
+	negLogLikelihood := func(theta []float64) float64 {
+		model := toModel(theta)
+		var ll float64
+		for _, h := range repeat {
+			ll += GammaGammaLogLikelihood(model, h)
+		}
+		return -ll
+	}
+
+	start := []float64{0, 0, math.Log(math.Max(meanValue, 1))}
+	theta := nelderMead(negLogLikelihood, start, maxIterations, 1e-8)
+	if math.IsInf(safeEval(negLogLikelihood, theta), 0) {
+		return nil, errors.New("Gamma-Gamma fit did not converge")
+	}
+
+	model := toModel(theta)
+	return &model, nil
+}
+
+// simulateLTV draws future purchase counts and spend over the next days from
+// the posterior of a customer's purchase rate, dropout probability and spend.
+func simulateLTV(bgnbd models.BGNBDModel, gg models.GammaGammaModel, h CustomerHistory, days float64, simulations int, rng *rand.Rand) (purchases, spend []float64) {
+	const maxPurchases = 10000
+
+	pAlive := BGNBDProbabilityAlive(bgnbd, h)
+	purchases = make([]float64, simulations)
+	spend = make([]float64, simulations)
+
+	for i := 0; i < simulations; i++ {
+		if rng.Float64() >= pAlive {
+			continue
+		}
+
+		rate := sampleGamma(rng, bgnbd.R+h.Frequency, bgnbd.Alpha+h.Age)
+		dropout := sampleBeta(rng, bgnbd.A, bgnbd.B+h.Frequency)
+		spendRate := sampleGamma(rng, gg.P*h.Frequency+gg.Q, gg.Gamma+h.Frequency*h.MonetaryValue)
+
+		var elapsed float64
+		for n := 0; n < maxPurchases; n++ {
+			elapsed += rng.ExpFloat64() / rate
+			if elapsed > days {
+				break
+			}
+			purchases[i]++
+			spend[i] += sampleGamma(rng, gg.P, spendRate)
+			if rng.Float64() < dropout {
+				break
+			}
+		}
+	}
+
+	return purchases, spend
+}
+
+// TrainLTVModel fits the BG/NBD purchase model and the Gamma-Gamma spend model
+// on every customer's purchase history.
+func (s *AnalyticsService) TrainLTVModel(ctx context.Context, req models.LTVTrainingRequest) (*models.TrainedModel, error) {
+	now := time.Now()
+
+	byCustomer, err := s.customerHistories(ctx, bson.M{}, now)
+	if err != nil {
+		return nil, err
+	}
+
+	histories := make([]CustomerHistory, 0, len(byCustomer))
+	var repeatCustomers int
+	var totalFrequency, totalRecency, totalAge, totalMonetary float64
+	for _, h := range byCustomer {
+		histories = append(histories, h)
+		totalFrequency += h.Frequency
+		totalRecency += h.Recency
+		totalAge += h.Age
+		if h.Frequency > 0 && h.MonetaryValue > 0 {
+			repeatCustomers++
+			totalMonetary += h.MonetaryValue
+		}
+	}
+	if len(histories) < 10 || repeatCustomers < 10 {
+		return nil, fmt.Errorf("%w: need at least 10 customers with repeat purchases, got %d customers (%d repeat)",
+			ErrInsufficientTrainingData, len(histories), repeatCustomers)
+	}
+
+	maxIterations := paramInt(req.Parameters, "max_iterations", 2000)
+	bgnbd, err := FitBGNBD(histories, maxIterations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fit BG/NBD model: %w", err)
+	}
+	gg, err := FitGammaGamma(histories, maxIterations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fit Gamma-Gamma model: %w", err)
+	}
+
+	var bgnbdLL, ggLL float64
+	for _, h := range histories {
+		bgnbdLL += BGNBDLogLikelihood(*bgnbd, h)
+		if h.Frequency > 0 && h.MonetaryValue > 0 {
+			ggLL += GammaGammaLogLikelihood(*gg, h)
+		}
+	}
+
+	window, err := s.trainingWindow(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	model := models.TrainedModel{
+		ID:             primitive.NewObjectID(),
+		ModelType:      ltvModelType,
+		TrainingWindow: window,
+		Parameters: map[string]interface{}{
+			"max_iterations": maxIterations,
+			"as_of":          now,
+		},
+		BGNBD:      bgnbd,
+		GammaGamma: gg,
+		Metrics: map[string]float64{
+			"bgnbd_log_likelihood":       bgnbdLL,
+			"gamma_gamma_log_likelihood": ggLL,
+			"repeat_customers":           float64(repeatCustomers),
+			"mean_frequency":             totalFrequency / float64(len(histories)),
+			"mean_recency":               totalRecency / float64(len(histories)),
+			"mean_age":                   totalAge / float64(len(histories)),
+			"mean_monetary_value":        totalMonetary / float64(repeatCustomers),
+		},
+		TrainingSamples: len(histories),
+		TrainedAt:       now,
+	}
+
+	if err := s.registerModel(ctx, &model); err != nil {
+		return nil, err
+	}
+
+	return &model, nil
+}
+
+// predictLifetimeValueWithModel returns a customer's expected purchases and
+// spend over the horizon, with Monte Carlo prediction intervals. Probability
+// is the chance the customer is still active. Customers without purchases
+// have a zero history and are scored as if starting now.
+func predictLifetimeValueWithModel(customer models.Customer, model *models.TrainedModel, history CustomerHistory, horizonMonths int, rng *rand.Rand, now time.Time) models.PredictionResult {
+	days := float64(horizonMonths) * daysPerMonth
+
+	expectedPurchases := BGNBDExpectedPurchases(*model.BGNBD, history, days)
+	expectedSpend := expectedPurchases * GammaGammaExpectedValue(*model.GammaGamma, history)
+
+	purchases, spend := simulateLTV(*model.BGNBD, *model.GammaGamma, history, days, ltvSimulations, rng)
+	tail := (1 - ltvIntervalLevel) / 2
+
+	return models.PredictionResult{
+		CustomerID:        customer.CustomerID,
+		PredictionType:    "ltv",
+		Probability:       BGNBDProbabilityAlive(*model.BGNBD, history),
+		Value:             expectedSpend,
+		Confidence:        ltvIntervalLevel,
+		HorizonMonths:     horizonMonths,
+		ExpectedPurchases: expectedPurchases,
+		ModelVersion:      model.Version,
+		Interval: &models.PredictionInterval{
+			Level:          ltvIntervalLevel,
+			PurchasesLower: percentile(purchases, tail),
+			PurchasesUpper: percentile(purchases, 1-tail),
+			ValueLower:     percentile(spend, tail),
+			ValueUpper:     percentile(spend, 1-tail),
+		},
+		CreatedAt: now,
+	}
+}
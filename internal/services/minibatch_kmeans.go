@@ -0,0 +1,184 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"context"
+	"fmt"
+	"math/rand"
+)
+
+// MiniBatchKMeansUpdate moves the centroids towards the points of one
+// mini-batch using per-centroid learning rates (Sculley, 2010). counts holds
+// the number of points each centroid has absorbed so far and is updated in
+// place.
+func MiniBatchKMeansUpdate(centroids [][]float64, counts []int, batch [][]float64) {
+	labels := make([]int, len(batch))
+	for i, point := range batch {
+		labels[i] = nearestCentroid(point, centroids)
+	}
+
+	for i, point := range batch {
+		c := labels[i]
+		counts[c]++
+		eta := 1 / float64(counts[c])
+		for j, v := range point {
+			centroids[c][j] = (1-eta)*centroids[c][j] + eta*v
+		}
+	}
+}
+
+// performMiniBatchSegmentation runs mini-batch k-means over a streaming
+// cursor. Memory is bounded by the batch size and the metrics sample rather
+// than the number of customers:
+//
+//  1. one pass gathers feature statistics and a reservoir sample used for
+//     k-means++ initialisation, auto_k and quality metrics;
+//  2. each epoch streams the sampled customers in mini-batches;
+//  3. a final pass assigns the sampled customers and writes memberships in
+//     batches.
+func (s *AnalyticsService) performMiniBatchSegmentation(ctx context.Context, run *models.SegmentationRun, vectorizer *customerVectorizer, sampled func(string) bool, params map[string]interface{}, rng *rand.Rand) ([]models.CustomerSegment, error) {
+	now := run.CreatedAt
+	dims := len(run.Features)
+	sampleSize := paramInt(params, "metrics_sample_size", 2000)
+
+	stats := newRunningStats(dims)
+	var reservoir [][]float64
+	err := s.streamCustomerRecords(ctx, vectorizer.usesStoredFeatures, func(customer customerRecord) error {
+		if !sampled(customer.CustomerID) {
+			return nil
+		}
+		point := vectorizer.vectorize(customer, now)
+		stats.add(point)
+		if len(reservoir) < sampleSize {
+			reservoir = append(reservoir, point)
+		} else if j := rng.Intn(stats.count); j < sampleSize {
+			reservoir[j] = point
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if stats.count == 0 {
+		return nil, errNoCustomers
+	}
+	run.CustomersConsidered = stats.count
+
+	means, stds := stats.result()
+	sample := make([][]float64, len(reservoir))
+	for i, point := range reservoir {
+		sample[i] = standardizePoint(point, means, stds)
+	}
+
+	curve, params, selectedK, err := chooseK(sample, params, rng)
+	if err != nil {
+		return nil, err
+	}
+
+	k := paramInt(params, "k", 3)
+	if k <= 0 {
+		return nil, fmt.Errorf("%w: k must be greater than zero", ErrInvalidParameter)
+	}
+	if len(sample) < k {
+		return nil, fmt.Errorf("%w: not enough data points (%d) for %d clusters", ErrInvalidParameter, len(sample), k)
+	}
+
+	batchSize := paramInt(params, "batch_size", 1024)
+	epochs := paramInt(params, "epochs", 3)
+	if batchSize <= 0 || epochs <= 0 {
+		return nil, fmt.Errorf("%w: batch_size and epochs must be greater than zero", ErrInvalidParameter)
+	}
+
+	centroids := kMeansPlusPlusInit(sample, k, rng)
+	counts := make([]int, k)
+	for epoch := 0; epoch < epochs; epoch++ {
+		batch := make([][]float64, 0, batchSize)
+		err := s.streamCustomerRecords(ctx, vectorizer.usesStoredFeatures, func(customer customerRecord) error {
+			if !sampled(customer.CustomerID) {
+				return nil
+			}
+			batch = append(batch, standardizePoint(vectorizer.vectorize(customer, now), means, stds))
+			if len(batch) == batchSize {
+				MiniBatchKMeansUpdate(centroids, counts, batch)
+				batch = batch[:0]
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) > 0 {
+			MiniBatchKMeansUpdate(centroids, counts, batch)
+		}
+	}
+
+	// Customers left out by sample_rate get no membership, as on the in-memory
+	// path
+	sizes := make([]int, k+1)
+	featureSums := make([][]float64, k+1)
+	for c := range featureSums {
+		featureSums[c] = make([]float64, dims)
+	}
+	var wcss float64
+	pending := make([]models.SegmentMembership, 0, membershipBatchSize)
+	err = s.streamCustomerRecords(ctx, vectorizer.usesStoredFeatures, func(customer customerRecord) error {
+		if !sampled(customer.CustomerID) {
+			return nil
+		}
+		point := vectorizer.vectorize(customer, now)
+		scaled := standardizePoint(point, means, stds)
+		c := nearestCentroid(scaled, centroids)
+
+		sizes[c]++
+		for j, v := range point {
+			featureSums[c][j] += v
+		}
+		wcss += squaredDistance(scaled, centroids[c])
+
+		pending = append(pending, models.SegmentMembership{
+			RunID:      run.RunID,
+			SegmentID:  segmentID(run.RunID, c),
+			CustomerID: customer.CustomerID,
+		})
+		if len(pending) == membershipBatchSize {
+			if err := s.saveSegmentMemberships(ctx, pending); err != nil {
+				return err
+			}
+			pending = pending[:0]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.saveSegmentMemberships(ctx, pending); err != nil {
+		return nil, err
+	}
+
+	for c := 0; c < k; c++ {
+		run.CustomerCount += sizes[c]
+	}
+
+	sampleLabels := make([]int, len(sample))
+	for i, point := range sample {
+		sampleLabels[i] = nearestCentroid(point, centroids)
+	}
+	run.Metrics = &models.ClusterQualityMetrics{
+		ClusterCount:       k,
+		SilhouetteScore:    SilhouetteScore(sample, sampleLabels),
+		DaviesBouldinIndex: DaviesBouldinIndex(sample, sampleLabels),
+		WCSS:               wcss,
+		SampleSize:         len(sample),
+		ElbowCurve:         curve,
+		SelectedK:          selectedK,
+	}
+
+	segments := buildClusterSegments("kmeans", run.Features, centroids, sizes, featureSums, map[string]interface{}{
+		"mini_batch": true,
+		"batch_size": batchSize,
+		"epochs":     epochs,
+	})
+	AssignSegmentIDs(run, segments)
+
+	return segments, nil
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	modelCandidate = "candidate"
+	modelActive    = "active"
+	modelArchived  = "archived"
+)
+
+var (
+	ErrModelNotFound    = errors.New("model not found")
+	ErrNoRollbackTarget = errors.New("no previously active model to roll back to")
+)
+
+// maxModelVersionAttempts bounds the retries of registerModel when a
+// concurrent training takes the version it picked
+const maxModelVersionAttempts = 5
+
+// registerModel assigns the next version number for the model type and saves
+// the model. The first version of a type becomes active straight away; later
+// ones are candidates until promoted. The unique (model_type, version) index
+// settles concurrent trainings: the one that loses a version retries with the
+// next, so only one of them can take version 1 and become active.
+func (s *AnalyticsService) registerModel(ctx context.Context, model *models.TrainedModel) error {
+	collection := s.db.Collection("ml_models")
+
+	for attempt := 0; attempt < maxModelVersionAttempts; attempt++ {
+		var latest models.TrainedModel
+		opts := options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}})
+		err := collection.FindOne(ctx, bson.M{"model_type": model.ModelType}, opts).Decode(&latest)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return fmt.Errorf("failed to get latest %s model: %w", model.ModelType, err)
+		}
+		model.Version = latest.Version + 1
+
+		model.Status = modelCandidate
+		model.PromotedAt = nil
+		if model.Version == 1 {
+			model.Status = modelActive
+			model.PromotedAt = &model.TrainedAt
+		}
+
+		_, err = collection.InsertOne(ctx, model)
+		if err == nil {
+			return nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("failed to save %s model: %w", model.ModelType, err)
+		}
+	}
+	return fmt.Errorf("failed to save %s model: no free version after %d attempts", model.ModelType, maxModelVersionAttempts)
+}
+
+// trainingWindow returns the range of purchase dates from the first purchase
+// up to end.
+func (s *AnalyticsService) trainingWindow(ctx context.Context, end time.Time) (models.TrainingWindow, error) {
+	var first models.Purchase
+	opts := options.FindOne().SetSort(bson.D{{Key: "purchase_date", Value: 1}})
+	err := s.db.Collection("purchases").FindOne(ctx, bson.M{"purchase_date": bson.M{"$lt": end}}, opts).Decode(&first)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return models.TrainingWindow{}, fmt.Errorf("failed to get first purchase: %w", err)
+	}
+	return models.TrainingWindow{Start: first.PurchaseDate, End: end}, nil
+}
+
+// activeModel returns the active model of a type, or nil when none has been
+// trained yet.
+func (s *AnalyticsService) activeModel(ctx context.Context, modelType string) (*models.TrainedModel, error) {
+	var model models.TrainedModel
+	err := s.db.Collection("ml_models").FindOne(ctx, bson.M{"model_type": modelType, "status": modelActive}).Decode(&model)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s model: %w", modelType, err)
+	}
+	return &model, nil
+}
+
+// GetModelVersions returns every version of a model type, newest first.
+func (s *AnalyticsService) GetModelVersions(ctx context.Context, modelType string) ([]models.TrainedModel, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "version", Value: -1}})
+	cursor, err := s.db.Collection("ml_models").Find(ctx, bson.M{"model_type": modelType}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s models: %w", modelType, err)
+	}
+	defer cursor.Close(ctx)
+
+	versions := []models.TrainedModel{}
+	if err = cursor.All(ctx, &versions); err != nil {
+		return nil, fmt.Errorf("failed to decode %s models: %w", modelType, err)
+	}
+
+	return versions, nil
+}
+
+// PromoteVersion makes a version the active one among the versions of a model
+// type, archiving the previously active version, and returns the versions
+// whose status changed, the promoted one first. Saving them in that order
+// means a failure part way leaves a model active.
+func PromoteVersion(versions []models.TrainedModel, version int, now time.Time) ([]*models.TrainedModel, error) {
+	var target *models.TrainedModel
+	for i := range versions {
+		if versions[i].Version == version {
+			target = &versions[i]
+		}
+	}
+	if target == nil {
+		return nil, ErrModelNotFound
+	}
+
+	target.Status = modelActive
+	target.PromotedAt = &now
+	changed := []*models.TrainedModel{target}
+	for i := range versions {
+		if versions[i].Status == modelActive && &versions[i] != target {
+			versions[i].Status = modelArchived
+			changed = append(changed, &versions[i])
+		}
+	}
+	return changed, nil
+}
+
+// RollbackVersion reactivates the version that was active before the current
+// one among the versions of a model type, and returns the versions whose
+// status changed, the reactivated one first. The rolled back version loses its
+// promotion time so it is not picked again, while the reactivated one keeps
+// its own so rolling back repeatedly walks further back through the
+// promotions.
+func RollbackVersion(versions []models.TrainedModel) ([]*models.TrainedModel, error) {
+	var active, previous *models.TrainedModel
+	for i := range versions {
+		if versions[i].Status == modelActive {
+			active = &versions[i]
+		}
+	}
+	if active == nil || active.PromotedAt == nil {
+		return nil, ErrNoRollbackTarget
+	}
+	for i := range versions {
+		candidate := &versions[i]
+		if candidate.Status != modelArchived || candidate.PromotedAt == nil || !candidate.PromotedAt.Before(*active.PromotedAt) {
+			continue
+		}
+		if previous == nil || candidate.PromotedAt.After(*previous.PromotedAt) {
+			previous = candidate
+		}
+	}
+	if previous == nil {
+		return nil, ErrNoRollbackTarget
+	}
+
+	active.Status = modelArchived
+	active.PromotedAt = nil
+	previous.Status = modelActive
+	return []*models.TrainedModel{previous, active}, nil
+}
+
+// PromoteModel makes a model version the active one for its type and archives
+// the previously active version.
+func (s *AnalyticsService) PromoteModel(ctx context.Context, modelType string, version int) (*models.TrainedModel, error) {
+	versions, err := s.GetModelVersions(ctx, modelType)
+	if err != nil {
+		return nil, err
+	}
+
+	changed, err := PromoteVersion(versions, version, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.saveModelStatuses(ctx, changed); err != nil {
+		return nil, err
+	}
+	return changed[0], nil
+}
+
+// RollbackModel reactivates the version that was active before the current
+// one. Rolling back repeatedly walks further back through the promotions.
+func (s *AnalyticsService) RollbackModel(ctx context.Context, modelType string) (*models.TrainedModel, error) {
+	versions, err := s.GetModelVersions(ctx, modelType)
+	if err != nil {
+		return nil, err
+	}
+
+	changed, err := RollbackVersion(versions)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.saveModelStatuses(ctx, changed); err != nil {
+		return nil, err
+	}
+	return changed[0], nil
+}
+
+// saveModelStatuses writes the status and promotion time of each model, in
+// order, so the model being activated is written before the ones archived.
+func (s *AnalyticsService) saveModelStatuses(ctx context.Context, changed []*models.TrainedModel) error {
+	for _, model := range changed {
+		set := bson.M{"status": model.Status}
+		update := bson.M{"$set": set}
+		if model.PromotedAt != nil {
+			set["promoted_at"] = *model.PromotedAt
+		} else {
+			update["$unset"] = bson.M{"promoted_at": ""}
+		}
+		if _, err := s.db.Collection("ml_models").UpdateOne(ctx, bson.M{"_id": model.ID}, update); err != nil {
+			return fmt.Errorf("failed to update %s model: %w", model.ModelType, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"math"
+	"sort"
+)
+
+// nelderMead minimises f starting from x0 with the Nelder-Mead simplex
+// method. It stops after maxIterations or once the function values across
+// the simplex differ by less than tolerance.
+func nelderMead(f func([]float64) float64, x0 []float64, maxIterations int, tolerance float64) []float64 {
+	const (
+		reflection  = 1.0
+		expansion   = 2.0
+		contraction = 0.5
+		shrink      = 0.5
+	)
+
+	n := len(x0)
+	simplex := make([][]float64, n+1)
+	values := make([]float64, n+1)
+	simplex[0] = append([]float64(nil), x0...)
+	for i := 0; i < n; i++ {
+		point := append([]float64(nil), x0...)
+		if point[i] != 0 {
+			point[i] *= 1.05
+		} else {
+			point[i] = 0.00025
+		}
+		simplex[i+1] = point
+	}
+	for i, point := range simplex {
+		values[i] = safeEval(f, point)
+	}
+
+	order := make([]int, n+1)
+	for iter := 0; iter < maxIterations; iter++ {
+		for i := range order {
+			order[i] = i
+		}
+		sort.Slice(order, func(a, b int) bool { return values[order[a]] < values[order[b]] })
+		sortedSimplex := make([][]float64, n+1)
+		sortedValues := make([]float64, n+1)
+		for i, idx := range order {
+			sortedSimplex[i], sortedValues[i] = simplex[idx], values[idx]
+		}
+		simplex, values = sortedSimplex, sortedValues
+
+		if math.Abs(values[n]-values[0]) < tolerance {
+			break
+		}
+
+		// Centroid of every point except the worst
+		centroid := make([]float64, n)
+		for _, point := range simplex[:n] {
+			for j, v := range point {
+				centroid[j] += v / float64(n)
+			}
+		}
+
+		along := func(coefficient float64) []float64 {
+			point := make([]float64, n)
+			for j := range point {
+				point[j] = centroid[j] + coefficient*(simplex[n][j]-centroid[j])
+			}
+			return point
+		}
+
+		reflected := along(-reflection)
+		reflectedValue := safeEval(f, reflected)
+
+		switch {
+		case reflectedValue < values[0]:
+			expanded := along(-expansion)
+			if expandedValue := safeEval(f, expanded); expandedValue < reflectedValue {
+				simplex[n], values[n] = expanded, expandedValue
+			} else {
+				simplex[n], values[n] = reflected, reflectedValue
+			}
+		case reflectedValue < values[n-1]:
+			simplex[n], values[n] = reflected, reflectedValue
+		default:
+			contracted := along(contraction)
+			if contractedValue := safeEval(f, contracted); contractedValue < values[n] {
+				simplex[n], values[n] = contracted, contractedValue
+			} else {
+				for i := 1; i <= n; i++ {
+					for j := range simplex[i] {
+						simplex[i][j] = simplex[0][j] + shrink*(simplex[i][j]-simplex[0][j])
+					}
+					values[i] = safeEval(f, simplex[i])
+				}
+			}
+		}
+	}
+
+	best := 0
+	for i := range values {
+		if values[i] < values[best] {
+			best = i
+		}
+	}
+	return simplex[best]
+}
+
+// safeEval maps NaN objective values to +Inf so the simplex moves away from
+// invalid regions.
+func safeEval(f func([]float64) float64, x []float64) float64 {
+	v := f(x)
+	if math.IsNaN(v) {
+		return math.Inf(1)
+	}
+	return v
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"context"
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// trendPredictionTypes lists the prediction types shown in a customer's
+// trend, with whether a rising value is a deterioration
+var trendPredictionTypes = []struct {
+	predictionType string
+	risingIsWorse  bool
+}{
+	{"churn", true},
+	{"ltv", false},
+}
+
+// predictionFilter matches a customer's predictions of a type (all types when
+// empty) created within the date range. Either end of the range may be zero.
+func predictionFilter(customerID, predictionType string, dateRange models.DateRange) bson.M {
+	filter := bson.M{"customer_id": customerID}
+	if predictionType != "" {
+		filter["prediction_type"] = predictionType
+	}
+
+	createdAt := bson.M{}
+	if !dateRange.StartDate.IsZero() {
+		createdAt["$gte"] = dateRange.StartDate
+	}
+	if !dateRange.EndDate.IsZero() {
+		createdAt["$lte"] = dateRange.EndDate
+	}
+	if len(createdAt) > 0 {
+		filter["created_at"] = createdAt
+	}
+	return filter
+}
+
+func (s *AnalyticsService) ensureCustomerExists(ctx context.Context, customerID string) error {
+	err := s.db.Collection("customers").FindOne(ctx, bson.M{"customer_id": customerID}).Err()
+	if err == mongo.ErrNoDocuments {
+		return ErrCustomerNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get customer: %w", err)
+	}
+	return nil
+}
+
+// GetCustomerPredictions returns a page of a customer's predictions, most
+// recent first, along with the total number matching the filters.
+func (s *AnalyticsService) GetCustomerPredictions(ctx context.Context, customerID, predictionType string, dateRange models.DateRange, limit, offset int) ([]models.PredictionResult, int64, error) {
+	if err := s.ensureCustomerExists(ctx, customerID); err != nil {
+		return nil, 0, err
+	}
+
+	collection := s.db.Collection("predictions")
+	filter := predictionFilter(customerID, predictionType, dateRange)
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count predictions: %w", err)
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(int64(limit)).
+		SetSkip(int64(offset))
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get predictions: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	predictions := []models.PredictionResult{}
+	if err = cursor.All(ctx, &predictions); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode predictions: %w", err)
+	}
+
+	return predictions, total, nil
+}
+
+// GetCustomerPredictionTrend returns how the customer's churn probability and
+// lifetime value evolved across the predictions made in the date range, with
+// one lifetime value trend per horizon.
+func (s *AnalyticsService) GetCustomerPredictionTrend(ctx context.Context, customerID string, dateRange models.DateRange) ([]models.PredictionTrend, error) {
+	if err := s.ensureCustomerExists(ctx, customerID); err != nil {
+		return nil, err
+	}
+
+	trends := []models.PredictionTrend{}
+	for _, trendType := range trendPredictionTypes {
+		opts := options.Find().
+			SetSort(bson.D{{Key: "created_at", Value: 1}}).
+			SetProjection(bson.M{"created_at": 1, "probability": 1, "value": 1, "model_version": 1, "horizon_months": 1})
+		cursor, err := s.db.Collection("predictions").Find(ctx,
+			predictionFilter(customerID, trendType.predictionType, dateRange), opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s predictions: %w", trendType.predictionType, err)
+		}
+
+		var predictions []models.PredictionResult
+		err = cursor.All(ctx, &predictions)
+		cursor.Close(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s predictions: %w", trendType.predictionType, err)
+		}
+		trends = append(trends, BuildPredictionTrends(trendType.predictionType, trendType.risingIsWorse, predictions)...)
+	}
+
+	return trends, nil
+}
+
+// BuildPredictionTrends summarizes predictions of one type, oldest first, as
+// one trend per horizon so only comparable values share a trend. Churn is
+// tracked by probability, everything else by value. Trends are ordered by
+// horizon.
+func BuildPredictionTrends(predictionType string, risingIsWorse bool, predictions []models.PredictionResult) []models.PredictionTrend {
+	byHorizon := make(map[int][]models.PredictionResult)
+	var horizons []int
+	for _, prediction := range predictions {
+		if byHorizon[prediction.HorizonMonths] == nil {
+			horizons = append(horizons, prediction.HorizonMonths)
+		}
+		byHorizon[prediction.HorizonMonths] = append(byHorizon[prediction.HorizonMonths], prediction)
+	}
+	sort.Ints(horizons)
+
+	trends := make([]models.PredictionTrend, 0, len(horizons))
+	for _, horizon := range horizons {
+		group := byHorizon[horizon]
+		trend := models.PredictionTrend{PredictionType: predictionType, HorizonMonths: horizon}
+		days := make([]float64, len(group))
+		values := make([]float64, len(group))
+		for i, prediction := range group {
+			value := prediction.Value
+			if predictionType == "churn" {
+				value = prediction.Probability
+			}
+			trend.Points = append(trend.Points, models.TrendPoint{
+				CreatedAt:    prediction.CreatedAt,
+				Value:        value,
+				ModelVersion: prediction.ModelVersion,
+			})
+			days[i] = prediction.CreatedAt.Sub(group[0].CreatedAt).Hours() / 24
+			values[i] = value
+		}
+
+		trend.First = values[0]
+		trend.Latest = values[len(values)-1]
+		trend.Change = trend.Latest - trend.First
+		trend.SlopePerMonth = TrendSlope(days, values) * daysPerMonth
+		if risingIsWorse {
+			trend.Deteriorating = trend.Change > 0
+		} else {
+			trend.Deteriorating = trend.Change < 0
+		}
+		trends = append(trends, trend)
+	}
+	return trends
+}
+
+// TrendSlope returns the least-squares slope of ys against xs, or 0 when the
+// xs do not vary.
+func TrendSlope(xs, ys []float64) float64 {
+	if len(xs) < 2 {
+		return 0
+	}
+
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= float64(len(xs))
+	meanY /= float64(len(ys))
+
+	var covariance, variance float64
+	for i := range xs {
+		covariance += (xs[i] - meanX) * (ys[i] - meanY)
+		variance += (xs[i] - meanX) * (xs[i] - meanX)
+	}
+	if variance == 0 {
+		return 0
+	}
+	return covariance / variance
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrPredictionJobNotFound = errors.New("prediction job not found")
+	ErrInvalidBatchSelection = errors.New("invalid batch selection")
+)
+
+const (
+	predictionJobRunning   = "running"
+	predictionJobCompleted = "completed"
+	predictionJobFailed    = "failed"
+
+	// predictionBatchSize is the number of customers scored and written per
+	// round trip
+	predictionBatchSize = 500
+
+	// predictionJobTimeout bounds how long a batch prediction job may run
+	predictionJobTimeout = 2 * time.Hour
+
+	// predictionJobFinishTimeout bounds the write that records how a job
+	// ended, which is made even after the job's own context has expired
+	predictionJobFinishTimeout = 10 * time.Second
+)
+
+// StartBatchPrediction validates a batch prediction request, records a job
+// and scores the selected customers in the background. The returned job can
+// be polled with GetPredictionJob.
+func (s *AnalyticsService) StartBatchPrediction(ctx context.Context, req models.BatchPredictionRequest) (*models.PredictionJob, error) {
+	selection, err := s.batchCustomerSelection(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	scorer, err := s.newPredictionScorer(ctx, req.PredictionTypes, req.HorizonMonths)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := s.countBatchCustomers(ctx, selection)
+	if err != nil {
+		return nil, err
+	}
+
+	job := models.PredictionJob{
+		ID:              primitive.NewObjectID(),
+		Status:          predictionJobRunning,
+		PredictionTypes: scorer.types,
+		SegmentID:       req.SegmentID,
+		All:             req.All,
+		HorizonMonths:   scorer.horizonMonths,
+		TotalCustomers:  int(total),
+		CreatedAt:       time.Now(),
+	}
+	job.JobID = job.ID.Hex()
+
+	if _, err := s.db.Collection("prediction_jobs").InsertOne(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to create prediction job: %w", err)
+	}
+
+	// The request context ends with the response, so the job gets its own
+	go func() {
+		jobCtx, cancel := context.WithTimeout(context.Background(), predictionJobTimeout)
+		defer cancel()
+		s.runBatchPrediction(jobCtx, job.JobID, selection, scorer)
+	}()
+
+	return &job, nil
+}
+
+// ValidateBatchSelection checks that a batch request selects its customers in
+// exactly one way.
+func ValidateBatchSelection(req models.BatchPredictionRequest) error {
+	selections := 0
+	if len(req.CustomerIDs) > 0 {
+		selections++
+	}
+	if req.SegmentID != "" {
+		selections++
+	}
+	if req.All {
+		selections++
+	}
+	if selections != 1 {
+		return fmt.Errorf("%w: provide exactly one of customer_ids, segment_id or all", ErrInvalidBatchSelection)
+	}
+	return nil
+}
+
+// batchSelection is the customers a batch prediction scores: the members of
+// a segment, or else the customers matching filter
+type batchSelection struct {
+	segmentID string
+	filter    bson.M
+}
+
+// batchCustomerSelection turns the customer selection of a batch request into
+// a batchSelection.
+func (s *AnalyticsService) batchCustomerSelection(ctx context.Context, req models.BatchPredictionRequest) (batchSelection, error) {
+	if err := ValidateBatchSelection(req); err != nil {
+		return batchSelection{}, err
+	}
+
+	switch {
+	case req.All:
+		return batchSelection{filter: bson.M{}}, nil
+	case req.SegmentID != "":
+		if err := s.ensureSegmentExists(ctx, req.SegmentID); err != nil {
+			return batchSelection{}, err
+		}
+		return batchSelection{segmentID: req.SegmentID}, nil
+	default:
+		return batchSelection{filter: bson.M{"customer_id": bson.M{"$in": req.CustomerIDs}}}, nil
+	}
+}
+
+func (s *AnalyticsService) countBatchCustomers(ctx context.Context, selection batchSelection) (int64, error) {
+	if selection.segmentID != "" {
+		total, err := s.db.Collection("segment_memberships").CountDocuments(ctx, bson.M{"segment_id": selection.segmentID})
+		if err != nil {
+			return 0, fmt.Errorf("failed to count segment members: %w", err)
+		}
+		return total, nil
+	}
+
+	total, err := s.db.Collection("customers").CountDocuments(ctx, selection.filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count customers: %w", err)
+	}
+	return total, nil
+}
+
+func (s *AnalyticsService) streamBatchCustomers(ctx context.Context, selection batchSelection, fn func(models.Customer) error) error {
+	if selection.segmentID != "" {
+		return s.streamSegmentCustomers(ctx, selection.segmentID, fn)
+	}
+	return s.streamCustomersMatching(ctx, selection.filter, fn)
+}
+
+// runBatchPrediction scores every selected customer in batches, writing the
+// predictions and the job progress after each batch. A panic fails the job
+// instead of leaving it running.
+func (s *AnalyticsService) runBatchPrediction(ctx context.Context, jobID string, selection batchSelection, scorer *predictionScorer) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Prediction job %s panicked: %v", jobID, r)
+			s.finishPredictionJob(ctx, jobID, fmt.Errorf("prediction job panicked: %v", r))
+		}
+	}()
+
+	var processed, written int
+	flush := func(customers []models.Customer) error {
+		predictions, err := scorer.score(ctx, customers)
+		if err != nil {
+			return err
+		}
+
+		docs := make([]interface{}, len(predictions))
+		for i, prediction := range predictions {
+			docs[i] = prediction
+		}
+		if _, err := s.db.Collection("predictions").InsertMany(ctx, docs, options.InsertMany().SetOrdered(false)); err != nil {
+			return fmt.Errorf("failed to save predictions: %w", err)
+		}
+
+		processed += len(customers)
+		written += len(predictions)
+		return s.updatePredictionJob(ctx, jobID, bson.M{
+			"processed_customers": processed,
+			"predictions_written": written,
+		})
+	}
+
+	batch := make([]models.Customer, 0, predictionBatchSize)
+	err := s.streamBatchCustomers(ctx, selection, func(customer models.Customer) error {
+		batch = append(batch, customer)
+		if len(batch) < predictionBatchSize {
+			return nil
+		}
+		err := flush(batch)
+		batch = batch[:0]
+		return err
+	})
+	if err == nil && len(batch) > 0 {
+		err = flush(batch)
+	}
+
+	s.finishPredictionJob(ctx, jobID, err)
+}
+
+// finishPredictionJob marks a job completed, or failed with err. The update
+// is made even when ctx has expired, since that may be why the job failed.
+func (s *AnalyticsService) finishPredictionJob(ctx context.Context, jobID string, err error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), predictionJobFinishTimeout)
+	defer cancel()
+
+	update := bson.M{"status": predictionJobCompleted, "completed_at": time.Now()}
+	if err != nil {
+		update["status"] = predictionJobFailed
+		update["error"] = err.Error()
+	}
+	if err := s.updatePredictionJob(ctx, jobID, update); err != nil {
+		log.Printf("Failed to finish prediction job %s: %v", jobID, err)
+	}
+}
+
+// FailInterruptedPredictionJobs marks jobs still running as failed. Jobs run
+// inside the server process, so at startup any such job was cut short by the
+// previous shutdown and will never finish.
+func (s *AnalyticsService) FailInterruptedPredictionJobs(ctx context.Context) (int64, error) {
+	update := bson.M{"$set": bson.M{
+		"status":       predictionJobFailed,
+		"error":        "prediction job was interrupted by a server restart",
+		"completed_at": time.Now(),
+	}}
+	result, err := s.db.Collection("prediction_jobs").UpdateMany(ctx, bson.M{"status": predictionJobRunning}, update)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail interrupted prediction jobs: %w", err)
+	}
+	return result.ModifiedCount, nil
+}
+
+func (s *AnalyticsService) updatePredictionJob(ctx context.Context, jobID string, fields bson.M) error {
+	_, err := s.db.Collection("prediction_jobs").UpdateOne(ctx, bson.M{"job_id": jobID}, bson.M{"$set": fields})
+	if err != nil {
+		return fmt.Errorf("failed to update prediction job: %w", err)
+	}
+	return nil
+}
+
+// GetPredictionJob returns a batch prediction job and its progress.
+func (s *AnalyticsService) GetPredictionJob(ctx context.Context, jobID string) (*models.PredictionJob, error) {
+	var job models.PredictionJob
+	err := s.db.Collection("prediction_jobs").FindOne(ctx, bson.M{"job_id": jobID}).Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrPredictionJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prediction job: %w", err)
+	}
+	return &job, nil
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// predictionScorer scores customers for a set of prediction types. Trained
+// models are loaded once so every customer scored by the same scorer shares
+// them.
+type predictionScorer struct {
+	service       *AnalyticsService
+	types         []string
+	horizonMonths int
+	churnModel    *models.TrainedModel
+	ltvModel      *models.TrainedModel
+	baseline      *customerBaseline // Average customer the heuristics are explained against, nil until features are computed
+	rng           *rand.Rand
+}
+
+func (s *AnalyticsService) newPredictionScorer(ctx context.Context, predictionTypes []string, horizonMonths int) (*predictionScorer, error) {
+	if horizonMonths == 0 {
+		horizonMonths = 12
+	}
+	if horizonMonths < 0 {
+		return nil, fmt.Errorf("%w: horizon_months must be positive", ErrInvalidHorizon)
+	}
+
+	scorer := &predictionScorer{
+		service:       s,
+		horizonMonths: horizonMonths,
+		rng:           rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	seen := make(map[string]bool)
+	for _, predictionType := range predictionTypes {
+		if seen[predictionType] {
+			continue
+		}
+		seen[predictionType] = true
+		scorer.types = append(scorer.types, predictionType)
+
+		switch predictionType {
+		case "churn":
+			// Prefer the active trained model, falling back to the recency heuristic
+			model, err := s.activeModel(ctx, churnModelType)
+			if err != nil {
+				return nil, err
+			}
+			if model != nil && model.Logistic != nil {
+				scorer.churnModel = model
+			}
+		case "ltv":
+			// Prefer the active BG/NBD and Gamma-Gamma models, falling back to
+			// the average order value heuristic until they are trained
+			model, err := s.activeModel(ctx, ltvModelType)
+			if err != nil {
+				return nil, err
+			}
+			// BG/NBD models with a at most 1 cannot project purchases
+			if model != nil && model.BGNBD != nil && model.BGNBD.A > 1 && model.GammaGamma != nil {
+				scorer.ltvModel = model
+			}
+		case "next_purchase":
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedPredictionType, predictionType)
+		}
+	}
+	if len(scorer.types) == 0 {
+		return nil, fmt.Errorf("%w: no prediction type given", ErrUnsupportedPredictionType)
+	}
+
+	// Heuristic predictions are explained against the baseline stored with the
+	// customer features, so scoring does not aggregate over all customers
+	if (seen["churn"] && scorer.churnModel == nil) || (seen["ltv"] && scorer.ltvModel == nil) || seen["next_purchase"] {
+		baseline, err := s.customerBaseline(ctx, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		scorer.baseline = baseline
+	}
+
+	return scorer, nil
+}
+
+// score returns one prediction per customer and prediction type, without
+// saving them. Purchase history is aggregated once for all the customers.
+func (p *predictionScorer) score(ctx context.Context, customers []models.Customer) ([]models.PredictionResult, error) {
+	now := time.Now()
+
+	customerIDs := make([]string, len(customers))
+	for i, customer := range customers {
+		customerIDs[i] = customer.CustomerID
+	}
+	filter := bson.M{"customer_id": bson.M{"$in": customerIDs}}
+
+	var snapshots map[string]purchaseSnapshot
+	if p.churnModel != nil {
+		var err error
+		if snapshots, err = p.service.purchaseSnapshots(ctx, filter, now, now); err != nil {
+			return nil, err
+		}
+	}
+	var histories map[string]CustomerHistory
+	if p.ltvModel != nil {
+		var err error
+		if histories, err = p.service.customerHistories(ctx, filter, now); err != nil {
+			return nil, err
+		}
+	}
+
+	predictions := make([]models.PredictionResult, 0, len(customers)*len(p.types))
+	for _, customer := range customers {
+		for _, predictionType := range p.types {
+			var prediction models.PredictionResult
+			switch predictionType {
+			case "churn":
+				if p.churnModel != nil {
+					snapshot := snapshots[customer.CustomerID]
+					prediction = predictChurnWithModel(customer, p.churnModel, snapshot, now)
+					prediction.Explanation = explainLogistic(p.churnModel.Logistic, churnFeatures(p.churnModel.Logistic.FeatureNames, customer, snapshot, now))
+				} else {
+					prediction = p.service.predictChurn(customer, now)
+					if p.baseline != nil {
+						prediction.Explanation = p.service.explainChurnHeuristic(customer, *p.baseline, now)
+					}
+				}
+			case "ltv":
+				if p.ltvModel != nil {
+					history := histories[customer.CustomerID]
+					prediction = predictLifetimeValueWithModel(customer, p.ltvModel, history, p.horizonMonths, p.rng, now)
+					prediction.Explanation = explainLifetimeValueModel(p.ltvModel, history, p.horizonMonths)
+				} else {
+					prediction = p.service.predictLifetimeValue(customer, p.horizonMonths, now)
+					if p.baseline != nil {
+						prediction.Explanation = p.service.explainLifetimeValueHeuristic(customer, p.horizonMonths, *p.baseline, now)
+					}
+				}
+			case "next_purchase":
+				prediction = p.service.predictNextPurchase(customer, now)
+				if p.baseline != nil {
+					prediction.Explanation = p.service.explainNextPurchase(customer, *p.baseline, now)
+				}
+			}
+			prediction.ID = primitive.NewObjectID()
+			predictions = append(predictions, prediction)
+		}
+	}
+
+	return predictions, nil
+}
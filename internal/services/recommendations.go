@@ -0,0 +1,350 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrProductNotFound = errors.New("product not found")
+
+const (
+	recommendationCollaborative = "collaborative"
+	recommendationPopular       = "popular"
+)
+
+// productStats counts the distinct customers who bought a product
+type productStats struct {
+	ProductID string `bson:"_id"`
+	Category  string `bson:"category"`
+	Buyers    int    `bson:"buyers"`
+}
+
+// productStats returns the stats of the products purchased in the matching
+// purchases, keyed by product ID.
+func (s *AnalyticsService) productStats(ctx context.Context, filter bson.M) (map[string]productStats, error) {
+	pipeline := []bson.M{
+		{"$match": filter},
+		{"$group": bson.M{
+			"_id":      bson.M{"product_id": "$product_id", "customer_id": "$customer_id"},
+			"category": bson.M{"$first": "$category"},
+		}},
+		{"$group": bson.M{
+			"_id":      "$_id.product_id",
+			"category": bson.M{"$first": "$category"},
+			"buyers":   bson.M{"$sum": 1},
+		}},
+	}
+
+	cursor, err := s.db.Collection("purchases").Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate product stats: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	stats := make(map[string]productStats)
+	for cursor.Next(ctx) {
+		var product productStats
+		if err := cursor.Decode(&product); err != nil {
+			return nil, fmt.Errorf("failed to decode product stats: %w", err)
+		}
+		stats[product.ProductID] = product
+	}
+	return stats, cursor.Err()
+}
+
+// coPurchaseBuyerBatchSize bounds the number of customers whose purchases are
+// fetched per query when building co-purchase baskets
+const coPurchaseBuyerBatchSize = 1000
+
+// CoPurchaseBaskets returns the distinct products of each customer other than
+// excludeCustomerID who bought at least one owned product, ordered by customer
+// ID. Purchases must hold every purchase of those customers so their baskets
+// include the products they bought besides the owned ones.
+func CoPurchaseBaskets(purchases []models.Purchase, owned []string, excludeCustomerID string) [][]string {
+	ownedSet := make(map[string]bool, len(owned))
+	for _, product := range owned {
+		ownedSet[product] = true
+	}
+
+	products := make(map[string]map[string]bool)
+	buyers := make(map[string]bool)
+	for _, purchase := range purchases {
+		if purchase.CustomerID == excludeCustomerID {
+			continue
+		}
+		if products[purchase.CustomerID] == nil {
+			products[purchase.CustomerID] = make(map[string]bool)
+		}
+		products[purchase.CustomerID][purchase.ProductID] = true
+		if ownedSet[purchase.ProductID] {
+			buyers[purchase.CustomerID] = true
+		}
+	}
+
+	customerIDs := make([]string, 0, len(buyers))
+	for customerID := range buyers {
+		customerIDs = append(customerIDs, customerID)
+	}
+	sort.Strings(customerIDs)
+
+	baskets := make([][]string, 0, len(customerIDs))
+	for _, customerID := range customerIDs {
+		basket := make([]string, 0, len(products[customerID]))
+		for product := range products[customerID] {
+			basket = append(basket, product)
+		}
+		sort.Strings(basket)
+		baskets = append(baskets, basket)
+	}
+	return baskets
+}
+
+// coPurchaseBaskets returns the full baskets of the customers other than
+// excludeCustomerID who bought an owned product, fetching their purchases a
+// batch of customers at a time.
+func (s *AnalyticsService) coPurchaseBaskets(ctx context.Context, owned []string, excludeCustomerID string) ([][]string, error) {
+	filter := bson.M{"product_id": bson.M{"$in": owned}}
+	if excludeCustomerID != "" {
+		filter["customer_id"] = bson.M{"$ne": excludeCustomerID}
+	}
+	buyers, err := s.db.Collection("purchases").Distinct(ctx, "customer_id", filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get co-purchase customers: %w", err)
+	}
+
+	var baskets [][]string
+	opts := options.Find().SetProjection(bson.M{"customer_id": 1, "product_id": 1})
+	for start := 0; start < len(buyers); start += coPurchaseBuyerBatchSize {
+		end := min(start+coPurchaseBuyerBatchSize, len(buyers))
+		cursor, err := s.db.Collection("purchases").Find(ctx, bson.M{"customer_id": bson.M{"$in": buyers[start:end]}}, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get co-purchase baskets: %w", err)
+		}
+		var purchases []models.Purchase
+		err = cursor.All(ctx, &purchases)
+		cursor.Close(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode co-purchase baskets: %w", err)
+		}
+		baskets = append(baskets, CoPurchaseBaskets(purchases, owned, excludeCustomerID)...)
+	}
+	return baskets, nil
+}
+
+// CoPurchaseScores scores the products outside owned that appear in the
+// baskets by their summed cosine similarity to the owned products, where the
+// similarity of two products is the number of customers who bought both over
+// the geometric mean of their buyer counts. The baskets must include every
+// customer who bought an owned product.
+func CoPurchaseScores(owned []string, baskets [][]string, buyers map[string]int) map[string]float64 {
+	ownedSet := make(map[string]bool, len(owned))
+	for _, product := range owned {
+		ownedSet[product] = true
+	}
+
+	coPurchases := make(map[[2]string]int)
+	for _, basket := range baskets {
+		for _, i := range basket {
+			if !ownedSet[i] {
+				continue
+			}
+			for _, j := range basket {
+				if !ownedSet[j] {
+					coPurchases[[2]string{i, j}]++
+				}
+			}
+		}
+	}
+
+	scores := make(map[string]float64)
+	for pair, count := range coPurchases {
+		i, j := pair[0], pair[1]
+		if buyers[i] == 0 || buyers[j] == 0 {
+			continue
+		}
+		scores[j] += float64(count) / math.Sqrt(float64(buyers[i]*buyers[j]))
+	}
+	return scores
+}
+
+// rankRecommendations returns the limit highest scored products, ties broken
+// by product ID.
+func rankRecommendations(scores map[string]float64, stats map[string]productStats, source string, limit int) []models.ProductRecommendation {
+	recommendations := make([]models.ProductRecommendation, 0, len(scores))
+	for productID, score := range scores {
+		recommendations = append(recommendations, models.ProductRecommendation{
+			ProductID: productID,
+			Category:  stats[productID].Category,
+			Score:     score,
+			Source:    source,
+		})
+	}
+	sort.Slice(recommendations, func(a, b int) bool {
+		if recommendations[a].Score != recommendations[b].Score {
+			return recommendations[a].Score > recommendations[b].Score
+		}
+		return recommendations[a].ProductID < recommendations[b].ProductID
+	})
+
+	if len(recommendations) > limit {
+		recommendations = recommendations[:limit]
+	}
+	return recommendations
+}
+
+// coPurchaseRecommendations ranks the products co-purchased with the owned
+// products by customers other than excludeCustomerID.
+func (s *AnalyticsService) coPurchaseRecommendations(ctx context.Context, owned []string, excludeCustomerID string, limit int) ([]models.ProductRecommendation, error) {
+	baskets, err := s.coPurchaseBaskets(ctx, owned, excludeCustomerID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	products := append([]string(nil), owned...)
+	for _, product := range owned {
+		seen[product] = true
+	}
+	for _, basket := range baskets {
+		for _, product := range basket {
+			if !seen[product] {
+				seen[product] = true
+				products = append(products, product)
+			}
+		}
+	}
+	stats, err := s.productStats(ctx, bson.M{"product_id": bson.M{"$in": products}})
+	if err != nil {
+		return nil, err
+	}
+
+	buyers := make(map[string]int, len(stats))
+	for productID, product := range stats {
+		buyers[productID] = product.Buyers
+	}
+	return rankRecommendations(CoPurchaseScores(owned, baskets, buyers), stats, recommendationCollaborative, limit), nil
+}
+
+// popularRecommendations ranks the products outside exclude by the share of
+// customers who bought them, restricted to the given categories when any.
+func (s *AnalyticsService) popularRecommendations(ctx context.Context, categories, exclude []string, limit int) ([]models.ProductRecommendation, error) {
+	filter := bson.M{"product_id": bson.M{"$nin": exclude}}
+	if len(categories) > 0 {
+		filter["category"] = bson.M{"$in": categories}
+	}
+	stats, err := s.productStats(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	customers, err := s.db.Collection("customers").EstimatedDocumentCount(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count customers: %w", err)
+	}
+	customers = max(customers, 1)
+
+	scores := make(map[string]float64, len(stats))
+	for productID, product := range stats {
+		scores[productID] = float64(product.Buyers) / float64(customers)
+	}
+	return rankRecommendations(scores, stats, recommendationPopular, limit), nil
+}
+
+// GetCustomerRecommendations recommends products the customer has not bought
+// yet from what customers with overlapping purchases bought. Customers with
+// little or no history are topped up with the most popular products of the
+// categories they bought or prefer, then with the most popular overall.
+func (s *AnalyticsService) GetCustomerRecommendations(ctx context.Context, customerID string, limit int) ([]models.ProductRecommendation, error) {
+	var customer models.Customer
+	err := s.db.Collection("customers").FindOne(ctx, bson.M{"customer_id": customerID}).Decode(&customer)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrCustomerNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer: %w", err)
+	}
+
+	owned, err := s.productStats(ctx, bson.M{"customer_id": customerID})
+	if err != nil {
+		return nil, err
+	}
+	exclude := make([]string, 0, len(owned))
+	var categories []string
+	if customer.PreferredCategory != "" {
+		categories = append(categories, customer.PreferredCategory)
+	}
+	for productID, product := range owned {
+		exclude = append(exclude, productID)
+		if product.Category != "" {
+			categories = append(categories, product.Category)
+		}
+	}
+
+	recommendations := []models.ProductRecommendation{}
+	if len(exclude) > 0 {
+		if recommendations, err = s.coPurchaseRecommendations(ctx, exclude, customerID, limit); err != nil {
+			return nil, err
+		}
+	}
+
+	// Cold start: fall back to category popularity, then overall popularity
+	fallbacks := [][]string{categories, nil}
+	if len(categories) == 0 {
+		fallbacks = fallbacks[1:]
+	}
+	for _, fallbackCategories := range fallbacks {
+		if len(recommendations) >= limit {
+			break
+		}
+		for _, recommendation := range recommendations {
+			exclude = append(exclude, recommendation.ProductID)
+		}
+		popular, err := s.popularRecommendations(ctx, fallbackCategories, exclude, limit-len(recommendations))
+		if err != nil {
+			return nil, err
+		}
+		recommendations = append(recommendations, popular...)
+	}
+
+	return recommendations, nil
+}
+
+// GetSimilarProducts returns the products most often bought by the same
+// customers as the given product, topped up with the most popular products
+// of its category.
+func (s *AnalyticsService) GetSimilarProducts(ctx context.Context, productID string, limit int) ([]models.ProductRecommendation, error) {
+	product, err := s.productStats(ctx, bson.M{"product_id": productID})
+	if err != nil {
+		return nil, err
+	}
+	if len(product) == 0 {
+		return nil, ErrProductNotFound
+	}
+
+	similar, err := s.coPurchaseRecommendations(ctx, []string{productID}, "", limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(similar) < limit && product[productID].Category != "" {
+		exclude := []string{productID}
+		for _, recommendation := range similar {
+			exclude = append(exclude, recommendation.ProductID)
+		}
+		popular, err := s.popularRecommendations(ctx, []string{product[productID].Category}, exclude, limit-len(similar))
+		if err != nil {
+			return nil, err
+		}
+		similar = append(similar, popular...)
+	}
+
+	return similar, nil
+}
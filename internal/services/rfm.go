@@ -0,0 +1,310 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const rfmAlgorithm = "rfm"
+
+// rfmSegments lists the named RFM segments in display order with their
+// descriptions.
+var rfmSegments = []struct {
+	name        string
+	description string
+}{
+	{"Champions", "Bought recently, buy often and spend the most"},
+	{"Loyal Customers", "Buy regularly and respond well to promotions"},
+	{"Potential Loyalists", "Recent customers with average frequency"},
+	{"New Customers", "Bought most recently, but not often"},
+	{"Promising", "Recent shoppers who have not spent much yet"},
+	{"Need Attention", "Above average recency, frequency and monetary values"},
+	{"About To Sleep", "Below average recency and frequency"},
+	{"At Risk", "Spent big money and purchased often, but long ago"},
+	{"Can't Lose Them", "Made the biggest and most frequent purchases, but have not returned"},
+	{"Hibernating", "Last purchase long ago, low spenders with few orders"},
+}
+
+// RFMSegmentName maps recency, frequency and monetary quintile scores (1-5)
+// to a named segment using the standard recency by frequency-monetary grid.
+func RFMSegmentName(r, f, m int) string {
+	fm := int(math.Round(float64(f+m) / 2))
+
+	switch {
+	case r >= 5 && fm >= 4:
+		return "Champions"
+	case r >= 3 && fm >= 4:
+		return "Loyal Customers"
+	case r >= 4 && fm >= 2:
+		return "Potential Loyalists"
+	case r >= 5:
+		return "New Customers"
+	case r >= 4:
+		return "Promising"
+	case r >= 3 && fm >= 3:
+		return "Need Attention"
+	case r >= 3:
+		return "About To Sleep"
+	case fm >= 5:
+		return "Can't Lose Them"
+	case fm >= 3:
+		return "At Risk"
+	default:
+		return "Hibernating"
+	}
+}
+
+// QuintileScores ranks values into scores from 1 (lowest fifth) to 5 (highest
+// fifth). Equal values always receive the same score.
+func QuintileScores(values []float64) []int {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return values[order[a]] < values[order[b]] })
+
+	scores := make([]int, len(values))
+	rank := 0
+	for i, idx := range order {
+		if i == 0 || values[idx] != values[order[i-1]] {
+			rank = i
+		}
+		scores[idx] = 1 + rank*5/len(values)
+	}
+	return scores
+}
+
+// rfmSegmentation is the outcome of scoring every customer on RFM
+type rfmSegmentation struct {
+	segments    []models.CustomerSegment
+	customerIDs []string
+	assignments []int
+}
+
+// performRFMSegmentation scores every customer on recency, frequency and
+// monetary value from the purchases collection, stores the scores and groups
+// customers into the named RFM segments.
+func (s *AnalyticsService) performRFMSegmentation(ctx context.Context, runID string, now time.Time) (*rfmSegmentation, error) {
+	pipeline := []bson.M{
+		{"$group": bson.M{
+			"_id":                "$customer_id",
+			"last_purchase_date": bson.M{"$max": "$purchase_date"},
+			"frequency":          bson.M{"$sum": 1},
+			"monetary":           bson.M{"$sum": "$amount"},
+		}},
+	}
+
+	cursor, err := s.db.Collection("purchases").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate purchases for RFM: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	type purchaseSummary struct {
+		CustomerID       string    `bson:"_id"`
+		LastPurchaseDate time.Time `bson:"last_purchase_date"`
+		Frequency        int       `bson:"frequency"`
+		Monetary         float64   `bson:"monetary"`
+	}
+	summaries := make(map[string]purchaseSummary)
+	for cursor.Next(ctx) {
+		var summary purchaseSummary
+		if err := cursor.Decode(&summary); err != nil {
+			return nil, fmt.Errorf("failed to decode purchase summary: %w", err)
+		}
+		summaries[summary.CustomerID] = summary
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read purchase summaries: %w", err)
+	}
+
+	// Score every known customer, including those who never purchased
+	customerCursor, err := s.db.Collection("customers").Find(ctx, bson.M{},
+		options.Find().SetProjection(bson.M{"customer_id": 1, "registration_date": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customers for RFM: %w", err)
+	}
+	defer customerCursor.Close(ctx)
+
+	var scores []models.RFMScore
+	for customerCursor.Next(ctx) {
+		var customer models.Customer
+		if err := customerCursor.Decode(&customer); err != nil {
+			return nil, fmt.Errorf("failed to decode customer: %w", err)
+		}
+
+		score := models.RFMScore{
+			CustomerID:  customer.CustomerID,
+			RecencyDays: now.Sub(customer.RegistrationDate).Hours() / 24,
+		}
+		if summary, ok := summaries[customer.CustomerID]; ok {
+			score.RecencyDays = now.Sub(summary.LastPurchaseDate).Hours() / 24
+			score.Frequency = summary.Frequency
+			score.Monetary = summary.Monetary
+		}
+		scores = append(scores, score)
+	}
+	if err := customerCursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read customers: %w", err)
+	}
+
+	if len(scores) == 0 {
+		return nil, errNoCustomers
+	}
+
+	recency := make([]float64, len(scores))
+	frequency := make([]float64, len(scores))
+	monetary := make([]float64, len(scores))
+	for i, score := range scores {
+		recency[i] = -score.RecencyDays // more recent scores higher
+		frequency[i] = float64(score.Frequency)
+		monetary[i] = score.Monetary
+	}
+	rScores, fScores, mScores := QuintileScores(recency), QuintileScores(frequency), QuintileScores(monetary)
+
+	segmentIndex := make(map[string]int, len(rfmSegments))
+	for i, segment := range rfmSegments {
+		segmentIndex[segment.name] = i
+	}
+
+	type segmentTotals struct {
+		size                         int
+		recency, frequency, monetary float64
+	}
+	totals := make([]segmentTotals, len(rfmSegments))
+	labels := make([]int, len(scores))
+	for i := range scores {
+		scores[i].RunID = runID
+		scores[i].RScore, scores[i].FScore, scores[i].MScore = rScores[i], fScores[i], mScores[i]
+		scores[i].RFMScore = fmt.Sprintf("%d%d%d", rScores[i], fScores[i], mScores[i])
+		scores[i].Segment = RFMSegmentName(rScores[i], fScores[i], mScores[i])
+		scores[i].ComputedAt = now
+
+		labels[i] = segmentIndex[scores[i].Segment]
+		t := &totals[labels[i]]
+		t.size++
+		t.recency += scores[i].RecencyDays
+		t.frequency += float64(scores[i].Frequency)
+		t.monetary += scores[i].Monetary
+	}
+
+	if err := s.saveRFMScores(ctx, scores); err != nil {
+		return nil, err
+	}
+
+	// Only keep named segments that have members
+	result := &rfmSegmentation{
+		customerIDs: make([]string, len(scores)),
+		assignments: make([]int, len(scores)),
+	}
+	positions := make([]int, len(rfmSegments))
+	for i, segment := range rfmSegments {
+		t := totals[i]
+		if t.size == 0 {
+			positions[i] = -1
+			continue
+		}
+		positions[i] = len(result.segments)
+		result.segments = append(result.segments, models.CustomerSegment{
+			Name:        segment.name,
+			Description: segment.description,
+			Size:        t.size,
+			Criteria: map[string]interface{}{
+				"algorithm":          rfmAlgorithm,
+				"avg_recency_days":   t.recency / float64(t.size),
+				"avg_frequency":      t.frequency / float64(t.size),
+				"avg_monetary_value": t.monetary / float64(t.size),
+			},
+		})
+	}
+	for i, score := range scores {
+		result.customerIDs[i] = score.CustomerID
+		result.assignments[i] = positions[labels[i]]
+	}
+
+	return result, nil
+}
+
+func (s *AnalyticsService) saveRFMScores(ctx context.Context, scores []models.RFMScore) error {
+	collection := s.db.Collection("rfm_scores")
+
+	for start := 0; start < len(scores); start += membershipBatchSize {
+		end := min(start+membershipBatchSize, len(scores))
+
+		writes := make([]mongo.WriteModel, 0, end-start)
+		for _, score := range scores[start:end] {
+			writes = append(writes, mongo.NewReplaceOneModel().
+				SetFilter(bson.M{"customer_id": score.CustomerID}).
+				SetReplacement(score).
+				SetUpsert(true))
+		}
+
+		if _, err := collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+			return fmt.Errorf("failed to save RFM scores: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetRFMScores returns the latest RFM scores, optionally restricted to one
+// named segment, ordered from the strongest RFM score down.
+func (s *AnalyticsService) GetRFMScores(ctx context.Context, segment string, limit, offset int) ([]models.RFMScore, error) {
+	filter := bson.M{}
+	if segment != "" {
+		filter["segment"] = segment
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "rfm_score", Value: -1}, {Key: "customer_id", Value: 1}}).
+		SetLimit(int64(limit)).
+		SetSkip(int64(offset))
+	cursor, err := s.db.Collection("rfm_scores").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get RFM scores: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	scores := []models.RFMScore{}
+	if err = cursor.All(ctx, &scores); err != nil {
+		return nil, fmt.Errorf("failed to decode RFM scores: %w", err)
+	}
+
+	return scores, nil
+}
+
+// rfmSegmentCounts returns the number of customers in each named RFM segment
+// according to the latest scores.
+func (s *AnalyticsService) rfmSegmentCounts(ctx context.Context) (map[string]int, error) {
+	pipeline := []bson.M{
+		{"$group": bson.M{"_id": "$segment", "count": bson.M{"$sum": 1}}},
+	}
+
+	cursor, err := s.db.Collection("rfm_scores").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	counts := make(map[string]int)
+	for cursor.Next(ctx) {
+		var result struct {
+			Segment string `bson:"_id"`
+			Count   int    `bson:"count"`
+		}
+		if err := cursor.Decode(&result); err != nil {
+			return nil, err
+		}
+		counts[result.Segment] = result.Count
+	}
+
+	return counts, cursor.Err()
+}
@@ -0,0 +1,483 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var errNoCustomers = errors.New("no customers found for segmentation")
+
+// customerCursorBatchSize is the number of customers fetched per round trip
+// when streaming the customers collection
+const customerCursorBatchSize = 1000
+
+// segmentationCleanupTimeout bounds how long removing a failed run may take
+const segmentationCleanupTimeout = 30 * time.Second
+
+// Segmentation run statuses
+const (
+	segmentationRunPending   = "pending"
+	segmentationRunCompleted = "completed"
+)
+
+func (s *AnalyticsService) PerformCustomerSegmentation(ctx context.Context, req models.SegmentationRequest) (*models.SegmentationRun, []models.CustomerSegment, error) {
+	algorithmName := strings.ToLower(req.Algorithm)
+	params := req.Parameters
+	seed := int64(paramFloat(params, "seed", float64(time.Now().UnixNano())))
+	rng := rand.New(rand.NewSource(seed))
+
+	// Every run gets its own ID so segment IDs never collide across runs
+	run := models.SegmentationRun{
+		ID:           primitive.NewObjectID(),
+		Algorithm:    algorithmName,
+		Features:     req.Features,
+		Parameters:   params,
+		SamplingRate: 1,
+		CreatedAt:    time.Now(),
+	}
+	run.RunID = run.ID.Hex()
+
+	// The elbow curve behind auto_k is a k-means curve
+	if paramBool(params, "auto_k", false) && algorithmName != "kmeans" {
+		return nil, nil, fmt.Errorf("%w: auto_k is only supported by kmeans", ErrInvalidParameter)
+	}
+
+	// The run is stored before any membership so memberships never point at a
+	// missing run; it stays pending until its segments are saved
+	run.Status = segmentationRunPending
+	if _, err := s.db.Collection("segmentation_runs").InsertOne(ctx, run); err != nil {
+		return nil, nil, fmt.Errorf("failed to save segmentation run: %w", err)
+	}
+
+	segments, err := s.segmentCustomers(ctx, &run, req, seed, rng)
+	if err == nil {
+		err = s.completeSegmentationRun(ctx, &run, segments)
+	}
+	if err != nil {
+		s.discardSegmentationRun(ctx, run.RunID)
+		return nil, nil, err
+	}
+
+	return &run, segments, nil
+}
+
+// segmentCustomers assigns the customers to segments with the requested
+// algorithm, writing their memberships under the run as it goes.
+func (s *AnalyticsService) segmentCustomers(ctx context.Context, run *models.SegmentationRun, req models.SegmentationRequest, seed int64, rng *rand.Rand) ([]models.CustomerSegment, error) {
+	algorithmName := run.Algorithm
+	params := req.Parameters
+
+	var segments []models.CustomerSegment
+
+	if algorithmName == rfmAlgorithm {
+		rfm, err := s.performRFMSegmentation(ctx, run.RunID, run.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		segments = rfm.segments
+		AssignSegmentIDs(run, segments)
+		if err := s.saveSegmentMemberships(ctx, BuildSegmentMemberships(run.RunID, segments, rfm.customerIDs, rfm.assignments)); err != nil {
+			return nil, err
+		}
+		run.CustomersConsidered = len(rfm.customerIDs)
+		run.CustomerCount = len(rfm.customerIDs)
+	} else {
+		algorithm, err := GetClusteringAlgorithm(req.Algorithm)
+		if err != nil {
+			return nil, err
+		}
+		vectorizer, err := customerFeatureVectorizer(req.Features)
+		if err != nil {
+			return nil, err
+		}
+
+		sampleRate := paramFloat(params, "sample_rate", 1)
+		if sampleRate <= 0 || sampleRate > 1 {
+			return nil, fmt.Errorf("%w: sample_rate must be in (0, 1]", ErrInvalidParameter)
+		}
+		run.SamplingRate = sampleRate
+		sampled := customerSampler(sampleRate, seed)
+
+		total, err := s.db.Collection("customers").CountDocuments(ctx, bson.M{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to count customers for segmentation: %w", err)
+		}
+		if total == 0 {
+			return nil, errNoCustomers
+		}
+
+		// Large bases are clustered with mini-batch k-means over a streaming
+		// cursor so memory stays bounded regardless of the number of customers
+		threshold := paramInt(params, "mini_batch_threshold", 10000)
+		if algorithmName == "kmeans" && float64(total)*sampleRate > float64(threshold) {
+			run.MiniBatch = true
+			segments, err = s.performMiniBatchSegmentation(ctx, run, vectorizer, sampled, params, rng)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			customerIDs, points, err := s.loadCustomerFeatures(ctx, vectorizer, sampled, run.CreatedAt)
+			if err != nil {
+				return nil, err
+			}
+			if len(points) == 0 {
+				return nil, errNoCustomers
+			}
+
+			clustering, err := performClusterSegmentation(points, algorithmName, algorithm, req.Features, params, rng)
+			if err != nil {
+				return nil, err
+			}
+
+			segments = clustering.segments
+			run.Metrics = clustering.metrics
+			AssignSegmentIDs(run, segments)
+			if err := s.saveSegmentMemberships(ctx, BuildSegmentMemberships(run.RunID, segments, customerIDs, clustering.assignments)); err != nil {
+				return nil, err
+			}
+			run.CustomersConsidered = len(customerIDs)
+			run.CustomerCount = len(customerIDs)
+		}
+	}
+
+	return segments, nil
+}
+
+// customerSampler returns a predicate that keeps roughly rate of all
+// customers. Selection hashes the customer ID, so the same customers are kept
+// on every pass over the collection.
+func customerSampler(rate float64, seed int64) func(customerID string) bool {
+	if rate >= 1 {
+		return func(string) bool { return true }
+	}
+
+	var seedBytes [8]byte
+	binary.LittleEndian.PutUint64(seedBytes[:], uint64(seed))
+
+	return func(customerID string) bool {
+		h := fnv.New64a()
+		h.Write(seedBytes[:])
+		h.Write([]byte(customerID))
+		return float64(h.Sum64()>>11)/(1<<53) < rate
+	}
+}
+
+// streamCustomers calls fn for every customer in the collection without
+// loading the whole collection into memory.
+func (s *AnalyticsService) streamCustomers(ctx context.Context, fn func(models.Customer) error) error {
+	return s.streamCustomersMatching(ctx, bson.M{}, fn)
+}
+
+// streamCustomersMatching calls fn for every customer matching filter.
+func (s *AnalyticsService) streamCustomersMatching(ctx context.Context, filter bson.M, fn func(models.Customer) error) error {
+	cursor, err := s.db.Collection("customers").Find(ctx, filter, options.Find().SetBatchSize(customerCursorBatchSize))
+	if err != nil {
+		return fmt.Errorf("failed to get customers: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var customer models.Customer
+		if err := cursor.Decode(&customer); err != nil {
+			return fmt.Errorf("failed to decode customer: %w", err)
+		}
+		if err := fn(customer); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// customerRecord is a customer joined with their feature store entry, when
+// one was requested and has been computed
+type customerRecord struct {
+	models.Customer `bson:",inline"`
+	StoredFeatures  []models.CustomerFeatures `bson:"stored_features,omitempty"`
+}
+
+// streamCustomerRecords calls fn for every customer, joining in their stored
+// features when withStoredFeatures is set.
+func (s *AnalyticsService) streamCustomerRecords(ctx context.Context, withStoredFeatures bool, fn func(customerRecord) error) error {
+	if !withStoredFeatures {
+		return s.streamCustomers(ctx, func(customer models.Customer) error {
+			return fn(customerRecord{Customer: customer})
+		})
+	}
+
+	pipeline := []bson.M{
+		{"$lookup": bson.M{
+			"from":         "customer_features",
+			"localField":   "customer_id",
+			"foreignField": "customer_id",
+			"as":           "stored_features",
+		}},
+	}
+	opts := options.Aggregate().SetBatchSize(customerCursorBatchSize).SetAllowDiskUse(true)
+	cursor, err := s.db.Collection("customers").Aggregate(ctx, pipeline, opts)
+	if err != nil {
+		return fmt.Errorf("failed to get customers with features: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var record customerRecord
+		if err := cursor.Decode(&record); err != nil {
+			return fmt.Errorf("failed to decode customer: %w", err)
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// loadCustomerFeatures streams the sampled customers and keeps only their IDs
+// and feature vectors.
+func (s *AnalyticsService) loadCustomerFeatures(ctx context.Context, vectorizer *customerVectorizer, sampled func(string) bool, now time.Time) ([]string, [][]float64, error) {
+	var customerIDs []string
+	var points [][]float64
+
+	err := s.streamCustomerRecords(ctx, vectorizer.usesStoredFeatures, func(customer customerRecord) error {
+		if sampled(customer.CustomerID) {
+			customerIDs = append(customerIDs, customer.CustomerID)
+			points = append(points, vectorizer.vectorize(customer, now))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load customers for segmentation: %w", err)
+	}
+
+	return customerIDs, points, nil
+}
+
+func segmentID(runID string, index int) string {
+	return fmt.Sprintf("%s_segment_%d", runID, index+1)
+}
+
+// AssignSegmentIDs stamps every segment with its index and an ID derived
+// from the run ID
+func AssignSegmentIDs(run *models.SegmentationRun, segments []models.CustomerSegment) {
+	run.SegmentIDs = make([]string, len(segments))
+	for i := range segments {
+		segments[i].ID = primitive.NewObjectID()
+		segments[i].SegmentID = segmentID(run.RunID, i)
+		segments[i].RunID = run.RunID
+		segments[i].Index = i
+		segments[i].CreatedAt = run.CreatedAt
+		segments[i].UpdatedAt = run.CreatedAt
+		run.SegmentIDs[i] = segments[i].SegmentID
+	}
+}
+
+// BuildSegmentMemberships records each customer as a member of the segment
+// at their assignment index.
+func BuildSegmentMemberships(runID string, segments []models.CustomerSegment, customerIDs []string, assignments []int) []models.SegmentMembership {
+	memberships := make([]models.SegmentMembership, len(customerIDs))
+	for i, customerID := range customerIDs {
+		memberships[i] = models.SegmentMembership{
+			RunID:      runID,
+			SegmentID:  segments[assignments[i]].SegmentID,
+			CustomerID: customerID,
+		}
+	}
+	return memberships
+}
+
+// completeSegmentationRun stores the segments of the run and marks it
+// completed. Memberships are written by the caller beforehand.
+func (s *AnalyticsService) completeSegmentationRun(ctx context.Context, run *models.SegmentationRun, segments []models.CustomerSegment) error {
+	segmentDocs := make([]interface{}, len(segments))
+	for i := range segments {
+		segmentDocs[i] = segments[i]
+	}
+
+	if _, err := s.db.Collection("customer_segments").InsertMany(ctx, segmentDocs); err != nil {
+		return fmt.Errorf("failed to save segments: %w", err)
+	}
+
+	run.Status = segmentationRunCompleted
+	if _, err := s.db.Collection("segmentation_runs").ReplaceOne(ctx, bson.M{"run_id": run.RunID}, run); err != nil {
+		return fmt.Errorf("failed to save segmentation run: %w", err)
+	}
+
+	return nil
+}
+
+// discardSegmentationRun removes everything written for a run that failed.
+// It runs even when ctx was cancelled, since that may be why the run failed.
+func (s *AnalyticsService) discardSegmentationRun(ctx context.Context, runID string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), segmentationCleanupTimeout)
+	defer cancel()
+
+	for _, collection := range []string{"segment_memberships", "customer_segments", "segmentation_runs"} {
+		if _, err := s.db.Collection(collection).DeleteMany(ctx, bson.M{"run_id": runID}); err != nil {
+			log.Printf("Failed to discard %s of segmentation run %s: %v", collection, runID, err)
+		}
+	}
+}
+
+// clusterSegmentation is the outcome of clustering customers: the segments,
+// the index of the segment each customer was assigned to, and the quality of
+// the clustering.
+type clusterSegmentation struct {
+	segments    []models.CustomerSegment
+	assignments []int
+	metrics     *models.ClusterQualityMetrics
+}
+
+// performClusterSegmentation clusters the customer feature vectors with the
+// given algorithm.
+func performClusterSegmentation(points [][]float64, algorithmName string, algorithm ClusteringAlgorithm, features []string, params map[string]interface{}, rng *rand.Rand) (*clusterSegmentation, error) {
+	// Standardize so that no single feature dominates the distance metric
+	scaled, _, _ := standardize(points)
+
+	metricsSampleSize := paramInt(params, "metrics_sample_size", 2000)
+	sample, _ := samplePoints(scaled, nil, metricsSampleSize, rng)
+
+	var curve []models.ElbowPoint
+	var selectedK int
+	if algorithmName == "kmeans" {
+		var err error
+		curve, params, selectedK, err = chooseK(sample, params, rng)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := algorithm.Cluster(scaled, params)
+	if err != nil {
+		return nil, fmt.Errorf("%s clustering failed: %w", algorithmName, err)
+	}
+
+	centroids := clusterCentroids(scaled, result.Labels)
+
+	// Index len(centroids) collects noise points, if the algorithm produces any
+	sizes := make([]int, len(centroids)+1)
+	featureSums := make([][]float64, len(centroids)+1)
+	for c := range featureSums {
+		featureSums[c] = make([]float64, len(features))
+	}
+	assignments := make([]int, len(result.Labels))
+	for i, label := range result.Labels {
+		if label == NoiseLabel {
+			label = len(centroids)
+		}
+		assignments[i] = label
+		sizes[label]++
+		for j, v := range points[i] {
+			featureSums[label][j] += v
+		}
+	}
+
+	// Silhouette is quadratic in the number of points, so it is computed on a
+	// bounded random sample
+	sampledPoints, sampledLabels := samplePoints(scaled, result.Labels, metricsSampleSize, rng)
+	metrics := &models.ClusterQualityMetrics{
+		ClusterCount:       len(centroids),
+		NoiseCount:         sizes[len(centroids)],
+		SilhouetteScore:    SilhouetteScore(sampledPoints, sampledLabels),
+		DaviesBouldinIndex: DaviesBouldinIndex(scaled, result.Labels),
+		WCSS:               WithinClusterSumOfSquares(scaled, result.Labels),
+		SampleSize:         len(sampledPoints),
+		ElbowCurve:         curve,
+		SelectedK:          selectedK,
+	}
+
+	return &clusterSegmentation{
+		segments:    buildClusterSegments(algorithmName, features, centroids, sizes, featureSums, result.Details),
+		assignments: assignments,
+		metrics:     metrics,
+	}, nil
+}
+
+// chooseK computes the elbow curve on the sample and, when auto_k is set,
+// returns a copy of params with k set to the best silhouette on the curve.
+func chooseK(sample [][]float64, params map[string]interface{}, rng *rand.Rand) ([]models.ElbowPoint, map[string]interface{}, int, error) {
+	curve := elbowCurve(sample, paramInt(params, "min_k", 2), paramInt(params, "max_k", 10), rng.Int63())
+
+	if !paramBool(params, "auto_k", false) {
+		return curve, params, 0, nil
+	}
+
+	k, ok := bestSilhouetteK(curve)
+	if !ok {
+		return nil, nil, 0, fmt.Errorf("%w: not enough customers to choose k automatically", ErrInvalidParameter)
+	}
+
+	tuned := make(map[string]interface{}, len(params)+1)
+	for key, value := range params {
+		tuned[key] = value
+	}
+	tuned["k"] = float64(k)
+
+	return curve, tuned, k, nil
+}
+
+// buildClusterSegments describes every cluster as a segment. sizes and
+// featureSums have one more entry than centroids, holding noise points.
+func buildClusterSegments(algorithmName string, features []string, centroids [][]float64, sizes []int, featureSums [][]float64, details map[string]interface{}) []models.CustomerSegment {
+	featureMeans := func(c int) map[string]float64 {
+		means := make(map[string]float64, len(features))
+		for j, feature := range features {
+			if sizes[c] > 0 {
+				means[feature] = featureSums[c][j] / float64(sizes[c])
+			}
+		}
+		return means
+	}
+
+	var segments []models.CustomerSegment
+	for c, centroid := range centroids {
+		centroidByFeature := make(map[string]float64, len(features))
+		for j, feature := range features {
+			centroidByFeature[feature] = centroid[j]
+		}
+
+		criteria := map[string]interface{}{
+			"algorithm":     algorithmName,
+			"centroid":      centroidByFeature,
+			"feature_means": featureMeans(c),
+		}
+		for key, value := range details {
+			criteria[key] = value
+		}
+
+		segments = append(segments, models.CustomerSegment{
+			Name:        fmt.Sprintf("Cluster %d", c+1),
+			Description: fmt.Sprintf("%s cluster of %d customers over %v", algorithmName, sizes[c], features),
+			Size:        sizes[c],
+			Criteria:    criteria,
+		})
+	}
+
+	if noise := len(centroids); sizes[noise] > 0 {
+		segments = append(segments, models.CustomerSegment{
+			Name:        "Outliers",
+			Description: fmt.Sprintf("%d customers not assigned to any %s cluster", sizes[noise], algorithmName),
+			Size:        sizes[noise],
+			Criteria: map[string]interface{}{
+				"algorithm":     algorithmName,
+				"noise":         true,
+				"feature_means": featureMeans(noise),
+			},
+		})
+	}
+
+	return segments
+}
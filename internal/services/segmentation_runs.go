@@ -0,0 +1,177 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrSegmentationRunNotFound = errors.New("segmentation run not found")
+
+// GetSegmentationRuns returns segmentation runs, most recent first.
+func (s *AnalyticsService) GetSegmentationRuns(ctx context.Context, limit, offset int) ([]models.SegmentationRun, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(int64(limit)).
+		SetSkip(int64(offset))
+	cursor, err := s.db.Collection("segmentation_runs").Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get segmentation runs: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	runs := []models.SegmentationRun{}
+	if err = cursor.All(ctx, &runs); err != nil {
+		return nil, fmt.Errorf("failed to decode segmentation runs: %w", err)
+	}
+
+	return runs, nil
+}
+
+// GetSegmentationRun returns a segmentation run together with its segments.
+func (s *AnalyticsService) GetSegmentationRun(ctx context.Context, runID string) (*models.SegmentationRun, []models.CustomerSegment, error) {
+	var run models.SegmentationRun
+	err := s.db.Collection("segmentation_runs").FindOne(ctx, bson.M{"run_id": runID}).Decode(&run)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil, ErrSegmentationRunNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get segmentation run: %w", err)
+	}
+
+	segments, err := s.getRunSegments(ctx, runID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &run, segments, nil
+}
+
+func (s *AnalyticsService) getRunSegments(ctx context.Context, runID string) ([]models.CustomerSegment, error) {
+	cursor, err := s.db.Collection("customer_segments").Find(ctx,
+		bson.M{"run_id": runID},
+		options.Find().SetSort(bson.D{{Key: "index", Value: 1}, {Key: "segment_id", Value: 1}}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get segments: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	segments := []models.CustomerSegment{}
+	if err = cursor.All(ctx, &segments); err != nil {
+		return nil, fmt.Errorf("failed to decode segments: %w", err)
+	}
+
+	return segments, nil
+}
+
+// runAssignments maps every customer in a run to the segment it was placed in.
+func (s *AnalyticsService) runAssignments(ctx context.Context, runID string) (map[string]string, error) {
+	opts := options.Find().SetProjection(bson.M{"customer_id": 1, "segment_id": 1})
+	cursor, err := s.db.Collection("segment_memberships").Find(ctx, bson.M{"run_id": runID}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get segment memberships: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	assignments := make(map[string]string)
+	for cursor.Next(ctx) {
+		var membership models.SegmentMembership
+		if err := cursor.Decode(&membership); err != nil {
+			return nil, fmt.Errorf("failed to decode segment membership: %w", err)
+		}
+		assignments[membership.CustomerID] = membership.SegmentID
+	}
+
+	return assignments, cursor.Err()
+}
+
+// DiffSegmentationRuns reports how customers moved between the segments of
+// two runs.
+func (s *AnalyticsService) DiffSegmentationRuns(ctx context.Context, fromRunID, toRunID string) (*models.SegmentationRunDiff, error) {
+	_, fromSegments, err := s.GetSegmentationRun(ctx, fromRunID)
+	if err != nil {
+		return nil, err
+	}
+	_, toSegments, err := s.GetSegmentationRun(ctx, toRunID)
+	if err != nil {
+		return nil, err
+	}
+
+	fromAssignments, err := s.runAssignments(ctx, fromRunID)
+	if err != nil {
+		return nil, err
+	}
+	toAssignments, err := s.runAssignments(ctx, toRunID)
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffRunAssignments(fromRunID, toRunID, fromSegments, toSegments, fromAssignments, toAssignments), nil
+}
+
+// DiffRunAssignments counts the customers added to, removed from and kept
+// between two runs, and how the kept ones moved between segments. Migrations
+// are ordered by the position of their segments within each run.
+func DiffRunAssignments(fromRunID, toRunID string, fromSegments, toSegments []models.CustomerSegment, fromAssignments, toAssignments map[string]string) *models.SegmentationRunDiff {
+	segmentNames := make(map[string]string)
+	segmentIndexes := make(map[string]int)
+	for _, segment := range append(fromSegments, toSegments...) {
+		segmentNames[segment.SegmentID] = segment.Name
+		segmentIndexes[segment.SegmentID] = segment.Index
+	}
+	segmentBefore := func(a, b string) bool {
+		if segmentIndexes[a] != segmentIndexes[b] {
+			return segmentIndexes[a] < segmentIndexes[b]
+		}
+		return a < b
+	}
+
+	diff := &models.SegmentationRunDiff{
+		FromRunID: fromRunID,
+		ToRunID:   toRunID,
+	}
+
+	type transition struct{ from, to string }
+	counts := make(map[transition]int)
+	for customerID, fromSegment := range fromAssignments {
+		toSegment, ok := toAssignments[customerID]
+		if !ok {
+			diff.RemovedCustomers++
+			continue
+		}
+		diff.CommonCustomers++
+		counts[transition{fromSegment, toSegment}]++
+	}
+	for customerID := range toAssignments {
+		if _, ok := fromAssignments[customerID]; !ok {
+			diff.AddedCustomers++
+		}
+	}
+
+	diff.Migrations = make([]models.SegmentMigration, 0, len(counts))
+	for t, count := range counts {
+		diff.Migrations = append(diff.Migrations, models.SegmentMigration{
+			FromSegmentID:   t.from,
+			FromSegmentName: segmentNames[t.from],
+			ToSegmentID:     t.to,
+			ToSegmentName:   segmentNames[t.to],
+			CustomerCount:   count,
+		})
+	}
+	sort.Slice(diff.Migrations, func(i, j int) bool {
+		a, b := diff.Migrations[i], diff.Migrations[j]
+		if a.FromSegmentID != b.FromSegmentID {
+			return segmentBefore(a.FromSegmentID, b.FromSegmentID)
+		}
+		return segmentBefore(a.ToSegmentID, b.ToSegmentID)
+	})
+
+	return diff
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"ai-analytics/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrSegmentNotFound  = errors.New("segment not found")
+	ErrCustomerNotFound = errors.New("customer not found")
+)
+
+// membershipBatchSize bounds the number of documents sent per InsertMany call
+const membershipBatchSize = 1000
+
+func (s *AnalyticsService) saveSegmentMemberships(ctx context.Context, memberships []models.SegmentMembership) error {
+	collection := s.db.Collection("segment_memberships")
+
+	for start := 0; start < len(memberships); start += membershipBatchSize {
+		end := min(start+membershipBatchSize, len(memberships))
+
+		docs := make([]interface{}, 0, end-start)
+		for _, membership := range memberships[start:end] {
+			membership.ID = primitive.NewObjectID()
+			membership.CreatedAt = time.Now()
+			docs = append(docs, membership)
+		}
+
+		if _, err := collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false)); err != nil {
+			return fmt.Errorf("failed to save segment memberships: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetSegmentCustomers returns a page of the customers that belong to a
+// segment, along with the total number of members.
+func (s *AnalyticsService) GetSegmentCustomers(ctx context.Context, segmentID string, limit, offset int) ([]models.Customer, int64, error) {
+	if err := s.ensureSegmentExists(ctx, segmentID); err != nil {
+		return nil, 0, err
+	}
+
+	membershipCollection := s.db.Collection("segment_memberships")
+	filter := bson.M{"segment_id": segmentID}
+
+	total, err := membershipCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count segment members: %w", err)
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "customer_id", Value: 1}}).
+		SetLimit(int64(limit)).
+		SetSkip(int64(offset))
+	cursor, err := membershipCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get segment members: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var memberships []models.SegmentMembership
+	if err = cursor.All(ctx, &memberships); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode segment members: %w", err)
+	}
+
+	if len(memberships) == 0 {
+		return []models.Customer{}, total, nil
+	}
+
+	customerIDs := make([]string, len(memberships))
+	for i, membership := range memberships {
+		customerIDs[i] = membership.CustomerID
+	}
+
+	customerCursor, err := s.db.Collection("customers").Find(ctx,
+		bson.M{"customer_id": bson.M{"$in": customerIDs}},
+		options.Find().SetSort(bson.D{{Key: "customer_id", Value: 1}}),
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get customers: %w", err)
+	}
+	defer customerCursor.Close(ctx)
+
+	var customers []models.Customer
+	if err = customerCursor.All(ctx, &customers); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode customers: %w", err)
+	}
+
+	return customers, total, nil
+}
+
+// ensureSegmentExists returns ErrSegmentNotFound unless the segment exists.
+func (s *AnalyticsService) ensureSegmentExists(ctx context.Context, segmentID string) error {
+	segmentCount, err := s.db.Collection("customer_segments").CountDocuments(ctx, bson.M{"segment_id": segmentID})
+	if err != nil {
+		return fmt.Errorf("failed to get segment: %w", err)
+	}
+	if segmentCount == 0 {
+		return ErrSegmentNotFound
+	}
+	return nil
+}
+
+// streamSegmentCustomers calls fn for every customer in a segment. Members are
+// joined to their customers on the server, so no list of IDs is held.
+func (s *AnalyticsService) streamSegmentCustomers(ctx context.Context, segmentID string, fn func(models.Customer) error) error {
+	pipeline := []bson.M{
+		{"$match": bson.M{"segment_id": segmentID}},
+		{"$lookup": bson.M{
+			"from":         "customers",
+			"localField":   "customer_id",
+			"foreignField": "customer_id",
+			"as":           "customer",
+		}},
+		{"$unwind": "$customer"},
+		{"$replaceRoot": bson.M{"newRoot": "$customer"}},
+	}
+	opts := options.Aggregate().SetBatchSize(customerCursorBatchSize).SetAllowDiskUse(true)
+	cursor, err := s.db.Collection("segment_memberships").Aggregate(ctx, pipeline, opts)
+	if err != nil {
+		return fmt.Errorf("failed to get segment customers: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var customer models.Customer
+		if err := cursor.Decode(&customer); err != nil {
+			return fmt.Errorf("failed to decode segment customer: %w", err)
+		}
+		if err := fn(customer); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// GetCustomerSegments returns every segment the customer is a member of.
+func (s *AnalyticsService) GetCustomerSegments(ctx context.Context, customerID string) ([]models.CustomerSegment, error) {
+	if err := s.ensureCustomerExists(ctx, customerID); err != nil {
+		return nil, err
+	}
+
+	segmentIDs, err := s.db.Collection("segment_memberships").Distinct(ctx, "segment_id", bson.M{"customer_id": customerID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer memberships: %w", err)
+	}
+
+	segments := []models.CustomerSegment{}
+	if len(segmentIDs) == 0 {
+		return segments, nil
+	}
+
+	cursor, err := s.db.Collection("customer_segments").Find(ctx,
+		bson.M{"segment_id": bson.M{"$in": segmentIDs}},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get segments: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, &segments); err != nil {
+		return nil, fmt.Errorf("failed to decode segments: %w", err)
+	}
+
+	return segments, nil
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// lnGamma returns the natural log of the gamma function.
+func lnGamma(x float64) float64 {
+	v, _ := math.Lgamma(x)
+	return v
+}
+
+// lnBeta returns the natural log of the beta function.
+func lnBeta(a, b float64) float64 {
+	return lnGamma(a) + lnGamma(b) - lnGamma(a+b)
+}
+
+// logAddExp returns log(exp(a) + exp(b)) without overflow.
+func logAddExp(a, b float64) float64 {
+	if math.IsInf(a, -1) {
+		return b
+	}
+	if math.IsInf(b, -1) {
+		return a
+	}
+	m := math.Max(a, b)
+	return m + math.Log(math.Exp(a-m)+math.Exp(b-m))
+}
+
+// hyp2f1 evaluates the Gaussian hypergeometric function 2F1(a, b; c; z) by
+// its power series, which converges for |z| < 1.
+func hyp2f1(a, b, c, z float64) float64 {
+	sum, term := 1.0, 1.0
+	for n := 0.0; n < 10000; n++ {
+		term *= (a + n) * (b + n) / ((c + n) * (n + 1)) * z
+		sum += term
+		if math.Abs(term) < 1e-12*math.Abs(sum) {
+			break
+		}
+	}
+	return sum
+}
+
+// sampleGamma draws from a gamma distribution with the given shape and rate
+// using the Marsaglia-Tsang method.
+func sampleGamma(rng *rand.Rand, shape, rate float64) float64 {
+	if shape < 1 {
+		// Boost the shape and correct with a uniform power
+		return sampleGamma(rng, shape+1, rate) * math.Pow(rng.Float64(), 1/shape)
+	}
+
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rng.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rng.Float64()
+		if math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v / rate
+		}
+	}
+}
+
+// sampleBeta draws from a beta distribution.
+func sampleBeta(rng *rand.Rand, a, b float64) float64 {
+	x := sampleGamma(rng, a, 1)
+	y := sampleGamma(rng, b, 1)
+	return x / (x + y)
+}
+
+// percentile returns the q-th quantile (0-1) of values using linear
+// interpolation. values is sorted in place.
+func percentile(values []float64, q float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+
+	pos := q * float64(len(values)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+	if lower == upper {
+		return values[lower]
+	}
+	return values[lower] + (values[upper]-values[lower])*(pos-float64(lower))
+}
+
+// normalCDF returns the standard normal cumulative distribution at x.
+func normalCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+// normalQuantile returns the standard normal quantile of p in (0, 1).
+func normalQuantile(p float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*p-1)
+}
@@ -21,7 +21,7 @@ func ValidateStruct(s interface{}) error {
 		for _, err := range err.(validator.ValidationErrors) {
 			errors = append(errors, formatValidationError(err))
 		}
-		return fmt.Errorf(strings.Join(errors, ", "))
+		return fmt.Errorf("%s", strings.Join(errors, ", "))
 	}
 	return nil
 }
@@ -0,0 +1,40 @@
+package test
+
+import (
+	"ai-analytics/internal/services"
+	"math"
+	"testing"
+)
+
+func TestSeasonalResidualZScoresFlagsDropAgainstWeeklyCycle(t *testing.T) {
+	week := []float64{100, 110, 120, 115, 105, 200, 220}
+	y := make([]float64, 5*7)
+	for i := range y {
+		y[i] = week[i%7] + float64(i%3) // Small noise so residuals have spread
+	}
+	// A weekend day that looks normal for a weekday is still a drop
+	y[33] = 110
+
+	scores := services.SeasonalResidualZScores(y, 7, 28)
+	if scores[27].Scored {
+		t.Fatal("Expected no score before a full window of history")
+	}
+	if scores[33].ZScore > -3 {
+		t.Fatalf("Expected the weekend drop to stand out, got z %f", scores[33].ZScore)
+	}
+	if math.Abs(scores[29].ZScore) > 3 {
+		t.Fatalf("Expected a regular weekday not to be anomalous, got z %f", scores[29].ZScore)
+	}
+}
+
+func TestRollingZScoresSkipsMissingDays(t *testing.T) {
+	y := []float64{1, 2, 1, 2, math.NaN(), 1, 2, 10}
+
+	scores := services.RollingZScores(y, 7)
+	if scores[4].Scored {
+		t.Fatal("Expected missing days not to be scored")
+	}
+	if !scores[7].Scored || math.Abs(scores[7].Expected-1.5) > 1e-9 || scores[7].ZScore < 5 {
+		t.Fatalf("Expected a spike over the mean 1.5 of the observed days, got %+v", scores[7])
+	}
+}
@@ -0,0 +1,38 @@
+package test
+
+import (
+	"ai-analytics/internal/services"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAttributionWeights(t *testing.T) {
+	purchase := time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+	touchpoints := []time.Time{purchase.AddDate(0, 0, -14), purchase.AddDate(0, 0, -7), purchase.AddDate(0, 0, -3), purchase}
+	halfLife := 7 * 24 * time.Hour
+
+	expected := map[string][]float64{
+		"last_touch":     {0, 0, 0, 1},
+		"first_touch":    {1, 0, 0, 0},
+		"linear":         {0.25, 0.25, 0.25, 0.25},
+		"position_based": {0.4, 0.1, 0.1, 0.4},
+	}
+	for model, want := range expected {
+		got := services.AttributionWeights(model, touchpoints, purchase, halfLife)
+		for i := range want {
+			if math.Abs(got[i]-want[i]) > 1e-9 {
+				t.Fatalf("%s: expected %v, got %v", model, want, got)
+			}
+		}
+	}
+
+	decay := services.AttributionWeights("time_decay", touchpoints, purchase, halfLife)
+	var total float64
+	for _, weight := range decay {
+		total += weight
+	}
+	if math.Abs(total-1) > 1e-9 || math.Abs(decay[1]/decay[0]-2) > 1e-9 {
+		t.Fatalf("Expected decay weights summing to 1 that double every half-life, got %v", decay)
+	}
+}
@@ -0,0 +1,35 @@
+package test
+
+import (
+	"ai-analytics/internal/services"
+	"math"
+	"testing"
+)
+
+func TestThresholdMetricsAt(t *testing.T) {
+	scores := []float64{0.9, 0.8, 0.6, 0.4, 0.2, 0.1}
+	labels := []float64{1, 1, 0, 1, 0, 0}
+
+	metrics := services.ThresholdMetricsAt(scores, labels, []float64{0.5})
+	if len(metrics) != 1 {
+		t.Fatalf("Expected 1 threshold, got %d", len(metrics))
+	}
+	// 3 predicted positive, 2 of them correct, 3 actual positives
+	if math.Abs(metrics[0].Precision-2.0/3) > 1e-9 || math.Abs(metrics[0].Recall-2.0/3) > 1e-9 {
+		t.Fatalf("Unexpected precision/recall: %+v", metrics[0])
+	}
+}
+
+func TestCalibrationBuckets(t *testing.T) {
+	scores := []float64{0.05, 0.15, 0.95, 1.0}
+	labels := []float64{0, 1, 1, 1}
+
+	buckets := services.CalibrationBuckets(scores, labels, 10)
+	if len(buckets) != 3 {
+		t.Fatalf("Expected 3 non-empty buckets, got %d", len(buckets))
+	}
+	last := buckets[len(buckets)-1]
+	if last.Count != 2 || last.ObservedRate != 1 {
+		t.Fatalf("Expected a probability of 1 to share the top bucket, got %+v", last)
+	}
+}
@@ -0,0 +1,34 @@
+package test
+
+import (
+	"ai-analytics/internal/services"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestThompsonSamplingShares(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	shares := services.ThompsonSamplingShares(rng, []int{50, 80}, []int{1000, 1000}, 5000)
+	if shares[1] < 0.95 || math.Abs(shares[0]+shares[1]-1) > 1e-9 {
+		t.Fatalf("Expected the clearly better arm to get nearly all traffic, got %v", shares)
+	}
+
+	shares = services.ThompsonSamplingShares(rng, []int{50, 50}, []int{1000, 1000}, 5000)
+	if math.Abs(shares[0]-0.5) > 0.05 {
+		t.Fatalf("Expected an even split between identical arms, got %v", shares)
+	}
+}
+
+func TestUCB1Shares(t *testing.T) {
+	shares, bounds := services.UCB1Shares([]int{10, 0}, []int{100, 0}, 10)
+	if !math.IsInf(bounds[1], 1) || shares[1] < 0.1 {
+		t.Fatalf("Expected the untried arm to be pulled first, got shares %v and bounds %v", shares, bounds)
+	}
+
+	shares, _ = services.UCB1Shares([]int{50, 80}, []int{1000, 1000}, 1000)
+	if shares[1] <= shares[0] {
+		t.Fatalf("Expected the better arm to get more of the batch, got %v", shares)
+	}
+}
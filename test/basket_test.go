@@ -0,0 +1,40 @@
+package test
+
+import (
+	"ai-analytics/internal/services"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestAprioriAndAssociationRules(t *testing.T) {
+	baskets := [][]string{
+		{"bread", "butter", "milk"},
+		{"bread", "butter"},
+		{"bread", "butter", "jam"},
+		{"milk"},
+		{"jam", "milk"},
+	}
+
+	itemsets := services.Apriori(baskets, 0.4, 3)
+	counts := make(map[string]int)
+	for _, itemset := range itemsets {
+		counts[strings.Join(itemset.Items, "+")] = itemset.Count
+	}
+	if counts["bread+butter"] != 3 || counts["milk"] != 3 {
+		t.Fatalf("Unexpected frequent itemsets %v", counts)
+	}
+	if _, ok := counts["bread+butter+milk"]; ok {
+		t.Fatal("Expected the triple seen once to be infrequent")
+	}
+
+	rules := services.AssociationRules(itemsets, len(baskets), 0.5, 1)
+	if len(rules) != 2 {
+		t.Fatalf("Expected bread => butter and butter => bread, got %v", rules)
+	}
+	for _, rule := range rules {
+		if rule.Confidence != 1 || math.Abs(rule.Lift-5.0/3) > 1e-9 || rule.Support != 0.6 {
+			t.Fatalf("Unexpected rule %+v", rule)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package test
+
+import (
+	"ai-analytics/internal/models"
+	"ai-analytics/internal/services"
+	"math"
+	"testing"
+)
+
+func TestFitResponseCurveRecoversLogCurve(t *testing.T) {
+	var spend, outcome []float64
+	for x := 10.0; x <= 200; x += 10 {
+		spend = append(spend, x)
+		outcome = append(outcome, 500*math.Log1p(0.02*x))
+	}
+
+	curve := services.FitResponseCurve("log", spend, outcome)
+	if curve.RSquared < 0.999 {
+		t.Fatalf("Expected a near perfect fit, got R² %f with %v", curve.RSquared, curve.Parameters)
+	}
+	if got := services.ResponseCurveValue(curve, 100); math.Abs(got-500*math.Log1p(2)) > 5 {
+		t.Fatalf("Expected about %f at spend 100, got %f", 500*math.Log1p(2), got)
+	}
+}
+
+func TestAllocateBudgetEqualizesMarginalReturns(t *testing.T) {
+	strong := models.ResponseCurve{Type: "log", Parameters: map[string]float64{"scale": 200, "rate": 0.01}}
+	weak := models.ResponseCurve{Type: "log", Parameters: map[string]float64{"scale": 100, "rate": 0.01}}
+	curves := []models.ResponseCurve{strong, weak}
+
+	spend := services.AllocateBudget(curves, []float64{0, 0}, []float64{math.Inf(1), math.Inf(1)}, 300, 3000)
+	// Marginal returns 2/(1+0.01x) and 1/(1+0.01y) are equal at x = 100 + 2y
+	if math.Abs(spend[0]-700.0/3) > 1 || math.Abs(spend[1]-200.0/3) > 1 {
+		t.Fatalf("Expected about 233.3 and 66.7, got %v", spend)
+	}
+
+	capped := services.AllocateBudget(curves, []float64{0, 80}, []float64{150, math.Inf(1)}, 300, 3000)
+	if math.Abs(capped[0]-150) > 1e-6 || math.Abs(capped[1]-150) > 1e-6 {
+		t.Fatalf("Expected the bounds to hold, got %v", capped)
+	}
+}
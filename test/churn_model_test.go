@@ -0,0 +1,54 @@
+package test
+
+import (
+	"ai-analytics/internal/services"
+	"math"
+	"testing"
+)
+
+func TestTrainLogisticRegression(t *testing.T) {
+	// Churn is driven entirely by the first feature
+	var features [][]float64
+	var labels []float64
+	for i := 0; i < 40; i++ {
+		x := float64(i)
+		features = append(features, []float64{x, float64(i % 3)})
+		if i >= 20 {
+			labels = append(labels, 1)
+		} else {
+			labels = append(labels, 0)
+		}
+	}
+
+	model, err := services.TrainLogisticRegression(features, labels, []string{"recency", "noise"}, services.LogisticConfig{
+		LearningRate: 0.5,
+		Iterations:   2000,
+	})
+	if err != nil {
+		t.Fatalf("Failed to train model: %v", err)
+	}
+
+	if model.Coefficients[0] <= 0 {
+		t.Fatalf("Expected a positive coefficient for the predictive feature, got %f", model.Coefficients[0])
+	}
+	if p := services.PredictLogistic(model, []float64{35, 0}); p < 0.9 {
+		t.Fatalf("Expected high churn probability, got %f", p)
+	}
+	if p := services.PredictLogistic(model, []float64{5, 0}); p > 0.1 {
+		t.Fatalf("Expected low churn probability, got %f", p)
+	}
+}
+
+func TestAUC(t *testing.T) {
+	labels := []float64{0, 0, 1, 1}
+
+	if auc := services.AUC([]float64{0.1, 0.2, 0.8, 0.9}, labels); auc != 1 {
+		t.Fatalf("Expected perfect AUC, got %f", auc)
+	}
+	if auc := services.AUC([]float64{0.9, 0.8, 0.2, 0.1}, labels); auc != 0 {
+		t.Fatalf("Expected inverted AUC of 0, got %f", auc)
+	}
+	if auc := services.AUC([]float64{0.5, 0.5, 0.5, 0.5}, labels); math.Abs(auc-0.5) > 1e-9 {
+		t.Fatalf("Expected AUC of 0.5 for tied scores, got %f", auc)
+	}
+}
@@ -0,0 +1,162 @@
+package test
+
+import (
+	"ai-analytics/internal/services"
+	"errors"
+	"testing"
+)
+
+func TestKMeansSeparatesClusters(t *testing.T) {
+	points := [][]float64{
+		{0, 0}, {0.1, 0.2}, {0.2, 0.1},
+		{10, 10}, {10.1, 9.9}, {9.8, 10.2},
+	}
+
+	result, err := services.KMeans(points, services.KMeansConfig{K: 2, MaxIterations: 50, Tolerance: 1e-6, Seed: 42})
+	if err != nil {
+		t.Fatalf("KMeans failed: %v", err)
+	}
+
+	if len(result.Centroids) != 2 {
+		t.Fatalf("Expected 2 centroids, got %d", len(result.Centroids))
+	}
+
+	// The first three and last three points must share a label
+	for i := 1; i < 3; i++ {
+		if result.Labels[i] != result.Labels[0] {
+			t.Fatalf("Expected point %d in cluster %d, got %d", i, result.Labels[0], result.Labels[i])
+		}
+		if result.Labels[i+3] != result.Labels[3] {
+			t.Fatalf("Expected point %d in cluster %d, got %d", i+3, result.Labels[3], result.Labels[i+3])
+		}
+	}
+	if result.Labels[0] == result.Labels[3] {
+		t.Fatal("Expected the two groups to be assigned different clusters")
+	}
+}
+
+func TestKMeansRejectsTooFewPoints(t *testing.T) {
+	_, err := services.KMeans([][]float64{{1, 1}}, services.KMeansConfig{K: 3})
+	if !errors.Is(err, services.ErrInvalidParameter) {
+		t.Fatalf("Expected an invalid parameter error when there are fewer points than clusters, got %v", err)
+	}
+}
+
+func TestDBSCANMarksNoise(t *testing.T) {
+	points := [][]float64{
+		{0, 0}, {0.1, 0}, {0, 0.1},
+		{5, 5}, {5.1, 5}, {5, 5.1},
+		{20, 20},
+	}
+
+	labels, err := services.DBSCAN(points, 0.5, 3)
+	if err != nil {
+		t.Fatalf("DBSCAN failed: %v", err)
+	}
+
+	if labels[6] != services.NoiseLabel {
+		t.Fatalf("Expected isolated point to be noise, got label %d", labels[6])
+	}
+	if labels[0] == labels[3] {
+		t.Fatal("Expected the two dense groups to be different clusters")
+	}
+}
+
+func TestAgglomerativeClustering(t *testing.T) {
+	points := [][]float64{
+		{0, 0}, {0.2, 0.1},
+		{4, 4}, {4.1, 3.9},
+		{9, 0}, {9.2, 0.1},
+	}
+
+	for _, linkage := range []string{"single", "complete", "average", "ward"} {
+		labels, err := services.AgglomerativeClustering(points, 3, linkage, 0)
+		if err != nil {
+			t.Fatalf("%s linkage failed: %v", linkage, err)
+		}
+		for i := 0; i < len(points); i += 2 {
+			if labels[i] != labels[i+1] {
+				t.Fatalf("%s linkage: expected points %d and %d in the same cluster", linkage, i, i+1)
+			}
+		}
+		if labels[0] == labels[2] || labels[2] == labels[4] || labels[0] == labels[4] {
+			t.Fatalf("%s linkage: expected three distinct clusters, got %v", linkage, labels)
+		}
+	}
+}
+
+func TestGetClusteringAlgorithmRejectsUnknown(t *testing.T) {
+	if _, err := services.GetClusteringAlgorithm("dbscan"); err != nil {
+		t.Fatalf("Expected dbscan to be registered: %v", err)
+	}
+	if _, err := services.GetClusteringAlgorithm("spectral"); err == nil {
+		t.Fatal("Expected an error for an unregistered algorithm")
+	}
+}
+
+func TestClusterQualityMetrics(t *testing.T) {
+	points := [][]float64{
+		{0, 0}, {0, 1}, {1, 0},
+		{10, 10}, {10, 11}, {11, 10},
+	}
+	good := []int{0, 0, 0, 1, 1, 1}
+	bad := []int{0, 1, 0, 1, 0, 1}
+
+	if s := services.SilhouetteScore(points, good); s < 0.8 {
+		t.Fatalf("Expected a high silhouette for well separated clusters, got %f", s)
+	}
+	if services.SilhouetteScore(points, bad) >= services.SilhouetteScore(points, good) {
+		t.Fatal("Expected mixed clusters to have a lower silhouette")
+	}
+	if services.DaviesBouldinIndex(points, bad) <= services.DaviesBouldinIndex(points, good) {
+		t.Fatal("Expected mixed clusters to have a higher Davies-Bouldin index")
+	}
+
+	// Squared distances to each centroid are 2/9, 5/9 and 5/9, so WCSS is
+	// 4/3 per cluster
+	if wcss := services.WithinClusterSumOfSquares(points, good); wcss < 2.66 || wcss > 2.67 {
+		t.Fatalf("Expected WCSS of 8/3, got %f", wcss)
+	}
+}
+
+func TestMiniBatchKMeansUpdate(t *testing.T) {
+	centroids := [][]float64{{0, 0}, {10, 10}}
+	counts := make([]int, 2)
+
+	batch := [][]float64{{1, 1}, {1, 1}, {9, 9}, {9, 9}}
+	services.MiniBatchKMeansUpdate(centroids, counts, batch)
+
+	// With fresh counts the first point of each cluster replaces the
+	// centroid and the second averages into it
+	if centroids[0][0] != 1 || centroids[1][0] != 9 {
+		t.Fatalf("Expected centroids to move onto their points, got %v", centroids)
+	}
+	if counts[0] != 2 || counts[1] != 2 {
+		t.Fatalf("Expected two points absorbed per centroid, got %v", counts)
+	}
+}
+
+func TestClusteringRejectsInvalidParameters(t *testing.T) {
+	points := [][]float64{{0, 0}, {1, 1}, {2, 2}}
+
+	if _, err := services.DBSCAN(points, 0, 3); !errors.Is(err, services.ErrInvalidParameter) {
+		t.Fatalf("Expected an invalid parameter error for eps 0, got %v", err)
+	}
+	if _, err := services.AgglomerativeClustering(points, 2, "centroid", 0); !errors.Is(err, services.ErrInvalidParameter) {
+		t.Fatalf("Expected an invalid parameter error for an unknown linkage, got %v", err)
+	}
+
+	dbscan, err := services.GetClusteringAlgorithm("dbscan")
+	if err != nil {
+		t.Fatalf("Expected dbscan to be registered: %v", err)
+	}
+	// The limit is fixed at 5000 points and cannot be raised by the request
+	tooMany := make([][]float64, 5001)
+	for i := range tooMany {
+		tooMany[i] = []float64{float64(i), 0}
+	}
+	params := map[string]interface{}{"max_points": 10000}
+	if _, err := dbscan.Cluster(tooMany, params); !errors.Is(err, services.ErrInvalidParameter) {
+		t.Fatalf("Expected an invalid parameter error above the point limit, got %v", err)
+	}
+}
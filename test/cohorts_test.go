@@ -0,0 +1,28 @@
+package test
+
+import (
+	"ai-analytics/internal/services"
+	"testing"
+)
+
+func TestCohortRows(t *testing.T) {
+	// 2024-01 is month 2024*12, the current month is 2024-03
+	jan, mar := 2024*12, 2024*12+2
+	sizes := map[int]int{jan: 4, jan + 1: 0, mar: 2}
+	cells := []services.CohortCell{
+		{Cohort: jan, Offset: 0, Customers: 4, Revenue: 400},
+		{Cohort: jan, Offset: 2, Customers: 1, Revenue: 50},
+		{Cohort: mar, Offset: 0, Customers: 2, Revenue: 80},
+	}
+
+	rows := services.CohortRows(sizes, cells, jan, mar)
+	if len(rows) != 2 || rows[0].Cohort != "2024-01" || rows[1].Cohort != "2024-03" {
+		t.Fatalf("Expected the two non-empty cohorts, got %+v", rows)
+	}
+	if len(rows[0].Retention) != 3 || rows[0].Retention[1] != 0 || rows[0].Retention[2] != 0.25 {
+		t.Fatalf("Unexpected retention %v", rows[0].Retention)
+	}
+	if len(rows[1].Revenue) != 1 || rows[1].Revenue[0] != 80 {
+		t.Fatalf("Unexpected revenue %v", rows[1].Revenue)
+	}
+}
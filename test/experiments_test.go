@@ -0,0 +1,73 @@
+package test
+
+import (
+	"ai-analytics/internal/services"
+	"math"
+	"testing"
+)
+
+func TestTwoProportionZTest(t *testing.T) {
+	z, pValue, lower, upper := services.TwoProportionZTest(200, 1000, 250, 1000, 0.95)
+	if math.Abs(z-2.677) > 0.01 || math.Abs(pValue-0.0074) > 0.0005 {
+		t.Fatalf("Expected z 2.677 and p 0.0074, got %f and %f", z, pValue)
+	}
+	if math.Abs(lower-0.0135) > 0.0005 || math.Abs(upper-0.0865) > 0.0005 {
+		t.Fatalf("Expected interval [0.0135, 0.0865], got [%f, %f]", lower, upper)
+	}
+
+	if p := services.ProbabilityToBeatControl(200, 1000, 250, 1000); p < 0.99 || p > 1 {
+		t.Fatalf("Expected the variant to very likely beat control, got %f", p)
+	}
+	if p := services.ProbabilityToBeatControl(250, 1000, 200, 1000); p > 0.01 {
+		t.Fatalf("Expected the swapped variant to very likely lose, got %f", p)
+	}
+	if p := services.ProbabilityToBeatControl(50, 100, 50, 100); math.Abs(p-0.5) > 1e-9 {
+		t.Fatalf("Expected 0.5 for identical results, got %f", p)
+	}
+	// Large counts use the normal approximation, here with z about 2.44
+	if p := services.ProbabilityToBeatControl(30000, 100000, 30500, 100000); math.Abs(p-0.9927) > 0.002 {
+		t.Fatalf("Expected about 0.9927 for large counts, got %f", p)
+	}
+}
+
+func TestRequiredSampleSizeAndPower(t *testing.T) {
+	n := services.RequiredSampleSize(0.1, 0.2, 0.95, 0.8)
+	if n < 3835 || n > 3845 {
+		t.Fatalf("Expected about 3841 per variant, got %d", n)
+	}
+	if power := services.ProportionTestPower(0.1, 0.2, n, 0.95); math.Abs(power-0.8) > 0.005 {
+		t.Fatalf("Expected power 0.8 at the required sample size, got %f", power)
+	}
+	if services.RequiredSampleSize(0.9, 0.2, 0.95, 0.8) != 0 {
+		t.Fatalf("Expected no sample size for a lifted rate above one")
+	}
+}
+
+func TestProbabilityToBeatControlMatchesBetaPosteriors(t *testing.T) {
+	// Beta(2, 1) exceeds Beta(1, 2) with probability 5/6
+	if p := services.ProbabilityToBeatControl(0, 1, 1, 1); math.Abs(p-5.0/6) > 1e-9 {
+		t.Fatalf("Expected 5/6, got %f", p)
+	}
+
+	// Below the normal approximation's cutoff the exact sum is used; at these
+	// counts it should agree with the approximation of the Beta posteriors
+	posterior := func(successes, trials int) (mean, variance float64) {
+		a, b := float64(successes+1), float64(trials-successes+1)
+		return a / (a + b), a * b / ((a + b) * (a + b) * (a + b + 1))
+	}
+	cases := [][4]int{{200, 1000, 230, 1000}, {50, 400, 40, 300}, {900, 3000, 960, 3100}}
+	for _, c := range cases {
+		meanA, varA := posterior(c[0], c[1])
+		meanB, varB := posterior(c[2], c[3])
+		z := (meanB - meanA) / math.Sqrt(varA+varB)
+		expected := 0.5 * math.Erfc(-z/math.Sqrt2)
+
+		p := services.ProbabilityToBeatControl(c[0], c[1], c[2], c[3])
+		if math.IsNaN(p) || math.Abs(p-expected) > 0.01 {
+			t.Fatalf("Expected about %f for %v, got %f", expected, c, p)
+		}
+		if swapped := services.ProbabilityToBeatControl(c[2], c[3], c[0], c[1]); math.Abs(p+swapped-1) > 1e-9 {
+			t.Fatalf("Expected swapped probabilities for %v to sum to 1, got %f and %f", c, p, swapped)
+		}
+	}
+}
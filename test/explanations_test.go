@@ -0,0 +1,27 @@
+package test
+
+import (
+	"ai-analytics/internal/services"
+	"math"
+	"testing"
+)
+
+func TestShapleyValuesSumToPredictionDifference(t *testing.T) {
+	// An interaction term means attributions depend on the coalition
+	f := func(v []float64) float64 { return 2*v[0] + v[1]*v[2] }
+	x := []float64{3, 2, 5}
+	baseline := []float64{1, 1, 1}
+
+	values := services.ShapleyValues(f, x, baseline)
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	if want := f(x) - f(baseline); math.Abs(sum-want) > 1e-9 {
+		t.Fatalf("Expected Shapley values to sum to %f, got %f", want, sum)
+	}
+	if math.Abs(values[0]-4) > 1e-9 {
+		t.Fatalf("Expected the additive feature to get exactly its effect 4, got %f", values[0])
+	}
+}
@@ -0,0 +1,24 @@
+package test
+
+import (
+	"ai-analytics/internal/services"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestInterPurchaseStats(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// Gaps of 10 and 30 days, given out of order
+	dates := []time.Time{start.AddDate(0, 0, 40), start, start.AddDate(0, 0, 10)}
+
+	mean, std := services.InterPurchaseStats(dates)
+	if math.Abs(mean-20) > 1e-9 || math.Abs(std-10) > 1e-9 {
+		t.Fatalf("Expected mean 20 and std 10, got %v and %v", mean, std)
+	}
+
+	mean, std = services.InterPurchaseStats(dates[:1])
+	if mean != 0 || std != 0 {
+		t.Fatalf("Expected zero stats for a single purchase, got %v and %v", mean, std)
+	}
+}
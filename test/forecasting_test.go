@@ -0,0 +1,35 @@
+package test
+
+import (
+	"ai-analytics/internal/services"
+	"math"
+	"testing"
+)
+
+func TestHoltWintersForecastFollowsTrendAndSeason(t *testing.T) {
+	season := []float64{10, 12, 14, 13, 11, 20, 25}
+	y := make([]float64, 8*7)
+	for i := range y {
+		y[i] = 100 + 0.5*float64(i) + season[i%7]
+	}
+
+	forecast := services.HoltWintersForecast(y, 7, 14, 1.96)
+	for h, value := range forecast.Values {
+		i := len(y) + h
+		expected := 100 + 0.5*float64(i) + season[i%7]
+		if math.Abs(value-expected) > 0.5 {
+			t.Fatalf("Step %d: expected %f, got %f", h+1, expected, value)
+		}
+		if forecast.Lower[h] > value || forecast.Upper[h] < value {
+			t.Fatalf("Step %d: interval [%f, %f] does not contain %f", h+1, forecast.Lower[h], forecast.Upper[h], value)
+		}
+	}
+
+	naive := services.SeasonalNaiveForecast(y, 7, 7, 1.96)
+	if naive.Values[0] != y[len(y)-7] {
+		t.Fatalf("Expected the seasonal naive forecast to repeat last season, got %f", naive.Values[0])
+	}
+	if math.Abs(naive.RMSE-3.5) > 1e-9 {
+		t.Fatalf("Expected seasonal naive RMSE 3.5 from the trend, got %f", naive.RMSE)
+	}
+}
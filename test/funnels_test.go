@@ -0,0 +1,32 @@
+package test
+
+import (
+	"ai-analytics/internal/models"
+	"ai-analytics/internal/services"
+	"testing"
+	"time"
+)
+
+func TestFunnelProgressTakesFurthestAttemptWithinWindow(t *testing.T) {
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	at := func(hours float64) time.Time { return start.Add(time.Duration(hours * float64(time.Hour))) }
+	events := []models.Event{
+		{EventType: "view", Timestamp: at(0)},
+		{EventType: "add_to_cart", Timestamp: at(1)},
+		{EventType: "view", Timestamp: at(30)},
+		{EventType: "add_to_cart", Timestamp: at(31)},
+		{EventType: "checkout", Timestamp: at(32)},
+	}
+	steps := []string{"view", "add_to_cart", "checkout"}
+
+	// The first attempt's checkout falls outside its 24 hour window
+	reached, first := services.FunnelProgress(events, steps, 24*time.Hour)
+	if len(reached) != 3 || first != 2 || !reached[2].Equal(at(32)) {
+		t.Fatalf("Expected the second attempt to complete the funnel, got %v from event %d", reached, first)
+	}
+
+	reached, first = services.FunnelProgress(events[:2], steps, 24*time.Hour)
+	if len(reached) != 2 || first != 0 {
+		t.Fatalf("Expected two steps from the first event, got %v from event %d", reached, first)
+	}
+}
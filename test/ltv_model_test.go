@@ -0,0 +1,71 @@
+package test
+
+import (
+	"ai-analytics/internal/models"
+	"ai-analytics/internal/services"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestBGNBDExpectations(t *testing.T) {
+	model := models.BGNBDModel{R: 0.25, Alpha: 4, A: 1.8, B: 2.5}
+
+	recent := services.CustomerHistory{Frequency: 5, Recency: 350, Age: 360}
+	lapsed := services.CustomerHistory{Frequency: 5, Recency: 60, Age: 360}
+	if services.BGNBDProbabilityAlive(model, recent) <= services.BGNBDProbabilityAlive(model, lapsed) {
+		t.Fatal("Expected a recent purchaser to be more likely alive than a lapsed one")
+	}
+
+	sixMonths := services.BGNBDExpectedPurchases(model, recent, 182)
+	twoYears := services.BGNBDExpectedPurchases(model, recent, 730)
+	if sixMonths <= 0 || twoYears <= sixMonths {
+		t.Fatalf("Expected purchases to grow with the horizon, got %f then %f", sixMonths, twoYears)
+	}
+}
+
+func TestFitBGNBDRecoversPurchaseRate(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	const r, alpha = 2.0, 40.0
+
+	// Simulate customers from the BG/NBD process with gamma(2, 40) purchase
+	// rates and beta(1, 4) dropout probabilities
+	var histories []services.CustomerHistory
+	for i := 0; i < 2000; i++ {
+		h := services.CustomerHistory{Age: 200 + rng.Float64()*200}
+		rate := (rng.ExpFloat64() + rng.ExpFloat64()) / alpha
+		dropout := 1 - math.Pow(rng.Float64(), 0.25)
+
+		var elapsed float64
+		for {
+			elapsed += rng.ExpFloat64() / rate
+			if elapsed > h.Age {
+				break
+			}
+			h.Frequency++
+			h.Recency = elapsed
+			if rng.Float64() < dropout {
+				break
+			}
+		}
+		histories = append(histories, h)
+	}
+
+	fitted, err := services.FitBGNBD(histories, 3000)
+	if err != nil {
+		t.Fatalf("Failed to fit BG/NBD: %v", err)
+	}
+
+	if fitted.A <= 1 {
+		t.Fatalf("Expected a to be kept above 1, got %f", fitted.A)
+	}
+	if expected := services.BGNBDExpectedPurchases(*fitted, histories[0], 365); math.IsNaN(expected) || expected <= 0 {
+		t.Fatalf("Expected a positive number of purchases from the fitted model, got %f", expected)
+	}
+
+	want := r / alpha
+	got := fitted.R / fitted.Alpha
+	if math.Abs(got-want)/want > 0.3 {
+		t.Fatalf("Expected a mean purchase rate near %f, got %f (%+v)", want, got, *fitted)
+	}
+}
@@ -0,0 +1,77 @@
+package test
+
+import (
+	"ai-analytics/internal/models"
+	"ai-analytics/internal/services"
+	"errors"
+	"testing"
+	"time"
+)
+
+func registryVersions(start time.Time) []models.TrainedModel {
+	first, second := start, start.Add(time.Hour)
+	return []models.TrainedModel{
+		{ModelType: "churn", Version: 3, Status: "candidate"},
+		{ModelType: "churn", Version: 2, Status: "active", PromotedAt: &second},
+		{ModelType: "churn", Version: 1, Status: "archived", PromotedAt: &first},
+	}
+}
+
+func statusOf(versions []models.TrainedModel, version int) string {
+	for _, model := range versions {
+		if model.Version == version {
+			return model.Status
+		}
+	}
+	return ""
+}
+
+func TestPromoteVersion(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	versions := registryVersions(start)
+
+	now := start.Add(2 * time.Hour)
+	changed, err := services.PromoteVersion(versions, 3, now)
+	if err != nil {
+		t.Fatalf("PromoteVersion failed: %v", err)
+	}
+	if len(changed) != 2 || changed[0].Version != 3 {
+		t.Fatalf("Expected versions 3 and 2 to change with 3 first, got %d changes", len(changed))
+	}
+	if statusOf(versions, 3) != "active" || statusOf(versions, 2) != "archived" || statusOf(versions, 1) != "archived" {
+		t.Fatalf("Expected only version 3 to be active, got %+v", versions)
+	}
+	if !changed[0].PromotedAt.Equal(now) {
+		t.Fatalf("Expected version 3 promoted at %v, got %v", now, changed[0].PromotedAt)
+	}
+
+	if _, err := services.PromoteVersion(versions, 9, now); !errors.Is(err, services.ErrModelNotFound) {
+		t.Fatalf("Expected ErrModelNotFound for an unknown version, got %v", err)
+	}
+}
+
+func TestRollbackVersionWalksBack(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	versions := registryVersions(start)
+	if _, err := services.PromoteVersion(versions, 3, start.Add(2*time.Hour)); err != nil {
+		t.Fatalf("PromoteVersion failed: %v", err)
+	}
+
+	// Rolling back twice reactivates 2 and then 1
+	for _, expected := range []int{2, 1} {
+		changed, err := services.RollbackVersion(versions)
+		if err != nil {
+			t.Fatalf("RollbackVersion failed: %v", err)
+		}
+		if reactivated := changed[0]; reactivated.Version != expected || reactivated.Status != "active" {
+			t.Fatalf("Expected version %d to be reactivated, got %+v", expected, reactivated)
+		}
+		if changed[1].PromotedAt != nil {
+			t.Fatalf("Expected the rolled back version to lose its promotion time")
+		}
+	}
+
+	if _, err := services.RollbackVersion(versions); !errors.Is(err, services.ErrNoRollbackTarget) {
+		t.Fatalf("Expected ErrNoRollbackTarget once there is nothing to roll back to, got %v", err)
+	}
+}
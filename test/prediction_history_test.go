@@ -0,0 +1,39 @@
+package test
+
+import (
+	"ai-analytics/internal/models"
+	"ai-analytics/internal/services"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTrendSlope(t *testing.T) {
+	if slope := services.TrendSlope([]float64{0, 1, 2, 3}, []float64{1, 3, 5, 7}); math.Abs(slope-2) > 1e-9 {
+		t.Fatalf("Expected slope 2, got %f", slope)
+	}
+	if slope := services.TrendSlope([]float64{5, 5}, []float64{1, 2}); slope != 0 {
+		t.Fatalf("Expected slope 0 for constant x, got %f", slope)
+	}
+}
+
+func TestBuildPredictionTrendsSeparatesHorizons(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	predictions := []models.PredictionResult{
+		{Value: 100, HorizonMonths: 12, CreatedAt: start},
+		{Value: 300, HorizonMonths: 36, CreatedAt: start.AddDate(0, 0, 10)},
+		{Value: 90, HorizonMonths: 12, CreatedAt: start.AddDate(0, 0, 20)},
+		{Value: 320, HorizonMonths: 36, CreatedAt: start.AddDate(0, 0, 30)},
+	}
+
+	trends := services.BuildPredictionTrends("ltv", false, predictions)
+	if len(trends) != 2 || trends[0].HorizonMonths != 12 || trends[1].HorizonMonths != 36 {
+		t.Fatalf("Expected one trend each for 12 and 36 months, got %+v", trends)
+	}
+	if trends[0].First != 100 || trends[0].Latest != 90 || !trends[0].Deteriorating {
+		t.Fatalf("Expected the 12 month trend to fall from 100 to 90, got %+v", trends[0])
+	}
+	if trends[1].First != 300 || trends[1].Latest != 320 || trends[1].Deteriorating {
+		t.Fatalf("Expected the 36 month trend to rise from 300 to 320, got %+v", trends[1])
+	}
+}
@@ -0,0 +1,33 @@
+package test
+
+import (
+	"ai-analytics/internal/models"
+	"ai-analytics/internal/services"
+	"errors"
+	"testing"
+)
+
+func TestValidateBatchSelection(t *testing.T) {
+	valid := []models.BatchPredictionRequest{
+		{CustomerIDs: []string{"c1", "c2"}},
+		{SegmentID: "run_1_segment_1"},
+		{All: true},
+	}
+	for _, req := range valid {
+		if err := services.ValidateBatchSelection(req); err != nil {
+			t.Fatalf("Expected %+v to be a valid selection, got %v", req, err)
+		}
+	}
+
+	invalid := []models.BatchPredictionRequest{
+		{},
+		{CustomerIDs: []string{"c1"}, All: true},
+		{SegmentID: "run_1_segment_1", All: true},
+		{CustomerIDs: []string{"c1"}, SegmentID: "run_1_segment_1"},
+	}
+	for _, req := range invalid {
+		if err := services.ValidateBatchSelection(req); !errors.Is(err, services.ErrInvalidBatchSelection) {
+			t.Fatalf("Expected %+v to be rejected, got %v", req, err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package test
+
+import (
+	"ai-analytics/internal/models"
+	"ai-analytics/internal/services"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestCoPurchaseScores(t *testing.T) {
+	// A is bought with B twice and with C once; C also sells on its own
+	baskets := [][]string{{"A", "B"}, {"A", "B"}, {"A", "C"}, {"C"}, {"C"}}
+	buyers := map[string]int{"A": 3, "B": 2, "C": 3}
+
+	scores := services.CoPurchaseScores([]string{"A"}, baskets, buyers)
+	if _, ok := scores["A"]; ok {
+		t.Fatal("Expected owned products not to be scored")
+	}
+	if math.Abs(scores["B"]-2/math.Sqrt(6)) > 1e-9 || math.Abs(scores["C"]-1.0/3) > 1e-9 {
+		t.Fatalf("Unexpected scores %v", scores)
+	}
+}
+
+func TestCoPurchaseBasketsKeepBuyersFullBaskets(t *testing.T) {
+	purchases := []models.Purchase{
+		{CustomerID: "c1", ProductID: "A"},
+		{CustomerID: "c1", ProductID: "B"},
+		{CustomerID: "c1", ProductID: "B"},
+		{CustomerID: "c2", ProductID: "A"},
+		{CustomerID: "c2", ProductID: "C"},
+		{CustomerID: "c3", ProductID: "D"},
+		{CustomerID: "self", ProductID: "A"},
+		{CustomerID: "self", ProductID: "E"},
+	}
+
+	baskets := services.CoPurchaseBaskets(purchases, []string{"A"}, "self")
+	if len(baskets) != 2 || strings.Join(baskets[0], ",") != "A,B" || strings.Join(baskets[1], ",") != "A,C" {
+		t.Fatalf("Expected the full baskets of c1 and c2, got %v", baskets)
+	}
+
+	scores := services.CoPurchaseScores([]string{"A"}, baskets, map[string]int{"A": 2, "B": 1, "C": 1})
+	if scores["B"] <= 0 || scores["C"] <= 0 {
+		t.Fatalf("Expected products bought alongside A to be scored, got %v", scores)
+	}
+	if _, ok := scores["D"]; ok {
+		t.Fatal("Expected products of customers without an owned product not to be scored")
+	}
+	if _, ok := scores["E"]; ok {
+		t.Fatal("Expected the excluded customer's products not to be scored")
+	}
+}
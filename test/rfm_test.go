@@ -0,0 +1,47 @@
+package test
+
+import (
+	"ai-analytics/internal/services"
+	"testing"
+)
+
+func TestQuintileScores(t *testing.T) {
+	values := []float64{10, 20, 30, 40, 50, 60, 70, 80, 90, 100}
+	expected := []int{1, 1, 2, 2, 3, 3, 4, 4, 5, 5}
+
+	scores := services.QuintileScores(values)
+	for i := range expected {
+		if scores[i] != expected[i] {
+			t.Fatalf("Expected score %d for value %v, got %d", expected[i], values[i], scores[i])
+		}
+	}
+
+	// Ties must share a score
+	tied := services.QuintileScores([]float64{0, 0, 0, 0, 5})
+	for i := 1; i < 4; i++ {
+		if tied[i] != tied[0] {
+			t.Fatalf("Expected tied values to share a score, got %v", tied)
+		}
+	}
+}
+
+func TestRFMSegmentName(t *testing.T) {
+	cases := []struct {
+		r, f, m  int
+		expected string
+	}{
+		{5, 5, 5, "Champions"},
+		{4, 5, 4, "Loyal Customers"},
+		{5, 1, 1, "New Customers"},
+		{4, 1, 1, "Promising"},
+		{1, 4, 4, "At Risk"},
+		{1, 5, 5, "Can't Lose Them"},
+		{1, 1, 1, "Hibernating"},
+	}
+
+	for _, tc := range cases {
+		if name := services.RFMSegmentName(tc.r, tc.f, tc.m); name != tc.expected {
+			t.Fatalf("RFM %d%d%d: expected %s, got %s", tc.r, tc.f, tc.m, tc.expected, name)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package test
+
+import (
+	"ai-analytics/internal/models"
+	"ai-analytics/internal/services"
+	"testing"
+)
+
+func TestAssignSegmentIDs(t *testing.T) {
+	run := models.SegmentationRun{RunID: "run_1"}
+	segments := make([]models.CustomerSegment, 12)
+
+	services.AssignSegmentIDs(&run, segments)
+	for i, segment := range segments {
+		if segment.Index != i || segment.RunID != "run_1" || run.SegmentIDs[i] != segment.SegmentID {
+			t.Fatalf("Expected segment %d of run_1, got %+v", i, segment)
+		}
+	}
+	if segments[9].SegmentID != "run_1_segment_10" {
+		t.Fatalf("Expected the tenth segment to be run_1_segment_10, got %s", segments[9].SegmentID)
+	}
+}
+
+func TestDiffRunAssignments(t *testing.T) {
+	fromRun := models.SegmentationRun{RunID: "a"}
+	fromSegments := make([]models.CustomerSegment, 10)
+	services.AssignSegmentIDs(&fromRun, fromSegments)
+	toRun := models.SegmentationRun{RunID: "b"}
+	toSegments := make([]models.CustomerSegment, 2)
+	services.AssignSegmentIDs(&toRun, toSegments)
+
+	fromAssignments := map[string]string{
+		"c1": "a_segment_10",
+		"c2": "a_segment_2",
+		"c3": "a_segment_2",
+		"c4": "a_segment_1",
+	}
+	toAssignments := map[string]string{
+		"c1": "b_segment_1",
+		"c2": "b_segment_2",
+		"c3": "b_segment_2",
+		"c5": "b_segment_1",
+	}
+
+	diff := services.DiffRunAssignments("a", "b", fromSegments, toSegments, fromAssignments, toAssignments)
+	if diff.CommonCustomers != 3 || diff.AddedCustomers != 1 || diff.RemovedCustomers != 1 {
+		t.Fatalf("Expected 3 common, 1 added and 1 removed customer, got %+v", diff)
+	}
+
+	// Segments are ordered by index, so segment 2 comes before segment 10
+	expected := []models.SegmentMigration{
+		{FromSegmentID: "a_segment_2", ToSegmentID: "b_segment_2", CustomerCount: 2},
+		{FromSegmentID: "a_segment_10", ToSegmentID: "b_segment_1", CustomerCount: 1},
+	}
+	if len(diff.Migrations) != len(expected) {
+		t.Fatalf("Expected %d migrations, got %+v", len(expected), diff.Migrations)
+	}
+	for i, migration := range diff.Migrations {
+		if migration.FromSegmentID != expected[i].FromSegmentID || migration.ToSegmentID != expected[i].ToSegmentID || migration.CustomerCount != expected[i].CustomerCount {
+			t.Fatalf("Expected migration %d to be %+v, got %+v", i, expected[i], migration)
+		}
+	}
+}
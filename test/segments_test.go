@@ -0,0 +1,33 @@
+package test
+
+import (
+	"ai-analytics/internal/models"
+	"ai-analytics/internal/services"
+	"testing"
+)
+
+func TestBuildSegmentMemberships(t *testing.T) {
+	segments := []models.CustomerSegment{
+		{SegmentID: "run_1_segment_1"},
+		{SegmentID: "run_1_segment_2"},
+	}
+	customerIDs := []string{"c1", "c2", "c3"}
+
+	memberships := services.BuildSegmentMemberships("run_1", segments, customerIDs, []int{1, 0, 1})
+	if len(memberships) != len(customerIDs) {
+		t.Fatalf("Expected %d memberships, got %d", len(customerIDs), len(memberships))
+	}
+
+	expected := []string{"run_1_segment_2", "run_1_segment_1", "run_1_segment_2"}
+	for i, membership := range memberships {
+		if membership.CustomerID != customerIDs[i] {
+			t.Fatalf("Expected membership %d for customer %s, got %s", i, customerIDs[i], membership.CustomerID)
+		}
+		if membership.SegmentID != expected[i] {
+			t.Fatalf("Expected customer %s in segment %s, got %s", customerIDs[i], expected[i], membership.SegmentID)
+		}
+		if membership.RunID != "run_1" {
+			t.Fatalf("Expected run run_1, got %s", membership.RunID)
+		}
+	}
+}